@@ -4,8 +4,6 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-// TODO: Add unary expression + (container access + assignment)
-
 // AST Node
 type Node interface {
 	Pos() (line, column int)
@@ -43,22 +41,31 @@ func (s *StructType) String() string    { return s.Name }
 func (s *StructType) IsPrimitive() bool { return false }
 
 type ContainerType struct {
-	Kind        string
-	ElementType Type
-	Dimensions  int
-	Bounds      []Expression
-	IsDynamic   bool
+	Kind           string
+	KeyType        Type // Only for bảng_băm, ElementType holds the values
+	ElementType    Type
+	Dimensions     int
+	Bounds         []Expression
+	IsDynamic      bool
+	IsRuntimeSized bool // Some bound is only known when the program runs, like mảng[1..n]
+	IsOpen         bool // A mảng[] parameter, refers to the caller's array and takes its bounds
 }
 
 func (c *ContainerType) String() string {
+	if c.Kind == ContainerHashMap {
+		return c.Kind + " E " + c.KeyType.String() + " " + SymbolArrow + " " + c.ElementType.String()
+	}
 	str := c.Kind
+	if c.IsDynamic || c.IsOpen {
+		str += "[]"
+	}
 	str += " E " + c.ElementType.String()
 	return str
 }
 func (c *ContainerType) IsPrimitive() bool { return false }
 
 type Program struct {
-	Globals   []*Variable
+	Globals   []*VarDecl
 	Functions []*Function
 	Structs   []*StructDecl
 }
@@ -124,8 +131,19 @@ type VarDecl struct {
 func (v *VarDecl) statementNode()  {}
 func (v *VarDecl) Pos() (int, int) { return v.Line, v.Column }
 
+// Several variables declared in one statement, e.g. "biến a, b E Z32"
+type VarDeclList struct {
+	Decls  []*VarDecl
+	Line   int
+	Column int
+}
+
+func (v *VarDeclList) statementNode()  {}
+func (v *VarDeclList) Pos() (int, int) { return v.Line, v.Column }
+
 type ReturnStmt struct {
 	Value  Expression
+	Type   Type // Return type of the function, set by the type checker
 	Line   int
 	Column int
 }
@@ -144,6 +162,78 @@ type IfStmt struct {
 func (i *IfStmt) statementNode()  {}
 func (i *IfStmt) Pos() (int, int) { return i.Line, i.Column }
 
+type WhileStmt struct {
+	Label     string // Optional, for example "ngoài: trong khi ..."
+	Condition Expression
+	Body      []Statement
+	Line      int
+	Column    int
+}
+
+func (w *WhileStmt) statementNode()  {}
+func (w *WhileStmt) Pos() (int, int) { return w.Line, w.Column }
+
+// Counting loop: "cho i từ 1 đến n bước 2 thì ... kết thúc"
+type ForStmt struct {
+	Label  string    // Optional, for example "ngoài: cho ..."
+	Var    *Variable // Type is inferred from the bounds
+	Start  Expression
+	End    Expression
+	Step   Expression // nil means 1
+	Down   bool       // "xuống" counts downward
+	Body   []Statement
+	Line   int
+	Column int
+}
+
+func (f *ForStmt) statementNode()  {}
+func (f *ForStmt) Pos() (int, int) { return f.Line, f.Column }
+
+// "với mỗi x trong a thì ... kết thúc" visits the elements of an array or the rows of a matrix.
+// Ranges like "với mỗi i trong 1..n" are parsed as a ForStmt
+type ForEachStmt struct {
+	Label      string
+	Index      *Variable // Optional, "với mỗi i, x trong a" also gives the index of x
+	Var        *Variable // Type is inferred from the collection
+	Collection Expression
+	Body       []Statement
+	Line       int
+	Column     int
+}
+
+func (f *ForEachStmt) statementNode()  {}
+func (f *ForEachStmt) Pos() (int, int) { return f.Line, f.Column }
+
+// "dừng" leaves the innermost loop, or the loop with the given label
+type BreakStmt struct {
+	Label  string
+	Line   int
+	Column int
+}
+
+func (b *BreakStmt) statementNode()  {}
+func (b *BreakStmt) Pos() (int, int) { return b.Line, b.Column }
+
+// "tiếp tục" skips to the next iteration of the innermost loop, or the loop with the given label
+type ContinueStmt struct {
+	Label  string
+	Line   int
+	Column int
+}
+
+func (c *ContinueStmt) statementNode()  {}
+func (c *ContinueStmt) Pos() (int, int) { return c.Line, c.Column }
+
+type AssignStmt struct {
+	Target Expression // Identifier, IndexExpr or FieldExpr
+	Value  Expression
+	Line   int
+	Column int
+}
+
+func (a *AssignStmt) statementNode()  {}
+func (a *AssignStmt) Pos() (int, int) { return a.Line, a.Column }
+
 type RegExpr struct { // Is a statement
 	Expr   Expression
 	Line   int
@@ -182,9 +272,42 @@ type NumberLiteral struct {
 func (n *NumberLiteral) expressionNode() {}
 func (n *NumberLiteral) Pos() (int, int) { return n.Line, n.Column }
 
+type StringLiteral struct {
+	Value  string
+	Type   PrimitiveType
+	Line   int
+	Column int
+}
+
+func (s *StringLiteral) expressionNode() {}
+func (s *StringLiteral) Pos() (int, int) { return s.Line, s.Column }
+
+// A single code point, for example 'ă'. C32 until it's cast to C8 or C16
+type CharLiteral struct {
+	Value  rune
+	Type   PrimitiveType
+	Line   int
+	Column int
+}
+
+func (c *CharLiteral) expressionNode() {}
+func (c *CharLiteral) Pos() (int, int) { return c.Line, c.Column }
+
+// "đúng" or "sai"
+type BooleanLiteral struct {
+	Value  bool
+	Type   PrimitiveType
+	Line   int
+	Column int
+}
+
+func (b *BooleanLiteral) expressionNode() {}
+func (b *BooleanLiteral) Pos() (int, int) { return b.Line, b.Column }
+
 type StructLiteral struct {
 	StructName string
 	Fields     map[string]Expression
+	Type       Type
 	Line       int
 	Column     int
 }
@@ -207,6 +330,7 @@ type BinaryExpr struct {
 	Operator   string
 	Right      Expression
 	ReturnType PrimitiveType
+	MatrixType *ContainerType // Set instead of ReturnType when the result is a matrix
 	Line       int
 	Column     int
 }
@@ -214,6 +338,17 @@ type BinaryExpr struct {
 func (b *BinaryExpr) expressionNode() {}
 func (b *BinaryExpr) Pos() (int, int) { return b.Line, b.Column }
 
+type UnaryExpr struct {
+	Operator   string // SymbolMinus or SymbolBang ('không' is parsed as SymbolBang)
+	Operand    Expression
+	ReturnType PrimitiveType
+	Line       int
+	Column     int
+}
+
+func (u *UnaryExpr) expressionNode() {}
+func (u *UnaryExpr) Pos() (int, int) { return u.Line, u.Column }
+
 type CallExpr struct {
 	Name       string
 	Arguments  []Expression
@@ -235,9 +370,23 @@ type ExplicitCast struct {
 func (e *ExplicitCast) expressionNode() {}
 func (e *ExplicitCast) Pos() (int, int) { return e.Line, e.Column }
 
+type FieldExpr struct {
+	Object Expression
+	Field  string
+	Index  int // Position of the field in the struct, filled by the type checker
+	Type   Type
+	Line   int
+	Column int
+}
+
+func (f *FieldExpr) expressionNode() {}
+func (f *FieldExpr) Pos() (int, int) { return f.Line, f.Column }
+
 type IndexExpr struct {
 	Collection Expression
-	Indices    []Expression // Can support multi dimensional indexing
+	Indices    []Expression   // Can support multi dimensional indexing
+	SliceEnd   Expression     // a[i..j] is a view of the elements i to j, Indices holds i
+	SliceType  *ContainerType // Set by the type checker for slices
 	Line       int
 	Column     int
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 
 	"github.com/llir/llvm/ir"
@@ -17,12 +18,29 @@ type CodegenContext struct {
 	Func          *ir.Func
 	Block         *ir.Block
 	Symbols       map[string]value.Value
+	Globals       map[string]value.Value
+	Structs       map[string]types.Type
+	Functions     map[string]*Function // Declarations, for the parameter types at call sites
 	ifIDCounter   int
+	loopIDCounter int
 	flowIDCounter int
+	strIDCounter  int
+	arrIDCounter  int
+	strings       map[string]*ir.Global
+	loops         []loopTarget  // Enclosing loops, innermost last
+	owned         []value.Value // Runtime sized locals of the current function, see ownsStorage
 }
 
-// Function gen
-func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
+// Where "dừng" and "tiếp tục" branch to for one loop
+type loopTarget struct {
+	label         string
+	breakBlock    *ir.Block
+	continueBlock *ir.Block
+}
+
+// Function signature gen, done before any body so calls can be forward referenced
+func (fn *Function) Declare(ctx *CodegenContext) (*ir.Func, error) {
+	ctx.Functions[fn.Name] = fn
 	// Handle params
 	params := make([]*ir.Param, len(fn.Parameters))
 	for i, param := range fn.Parameters {
@@ -30,9 +48,12 @@ func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
 		if err != nil {
 			return nil, err
 		}
+		// Maps are passed by reference, changes made by the function are seen by the caller
+		if isHashMap(param.Type) {
+			paramType = types.NewPointer(paramType)
+		}
 		params[i] = ir.NewParam(param.Name, paramType)
 	}
-	var fnIR *ir.Func
 	if fn.Name == "chính" {
 		if !isSameTypeAndName(fn.ReturnType, &PrimitiveType{Name: PrimitiveZ32}) {
 			return nil, NewLangError(ReturnTypeMismatch, fn.ReturnType, PrimitiveZ32).At(fn.Line, fn.Column)
@@ -41,23 +62,39 @@ func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
 		if err != nil {
 			return nil, err
 		}
-		fnIR = ctx.Module.NewFunc("main", returnType, params...)
-	} else {
-		returnType, err := llvmTypeFromType(fn.ReturnType, ctx)
-		if err != nil {
-			return nil, err
-		}
-		fnIR = ctx.Module.NewFunc(fn.Name, returnType, params...)
+		return ctx.Module.NewFunc("main", returnType, params...), nil
+	}
+	returnType, err := llvmTypeFromType(fn.ReturnType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Module.NewFunc(fn.Name, returnType, params...), nil
+}
+
+// Function gen
+func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
+	name := fn.Name
+	if name == "chính" {
+		name = "main"
+	}
+	fnIR := findFunction(ctx.Module, name)
+	if fnIR == nil {
+		return nil, NewLangError(InvalidFunctionCall, fn.Name).At(fn.Line, fn.Column)
 	}
 
 	entry := fnIR.NewBlock("entry")
 	ctx.Func = fnIR
 	ctx.Block = entry
 	ctx.Symbols = make(map[string]value.Value) // fresh scope
+	ctx.owned = nil
 
 	// Map function parameters to allocas and store initial value
 	for i, param := range fn.Parameters {
 		llvmParam := fnIR.Params[i]
+		if isHashMap(param.Type) {
+			ctx.Symbols[param.Name] = llvmParam
+			continue
+		}
 		alloca := ctx.Block.NewAlloca(llvmParam.Type())
 		ctx.Symbols[param.Name] = alloca
 		ctx.Block.NewStore(llvmParam, alloca)
@@ -73,6 +110,8 @@ func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
 	// If no explicit return, add default return 0
 	if !blockHasTerminator(ctx.Block) {
 		switch fnIR.Sig.RetType {
+		case types.I1:
+			ctx.Block.NewRet(constant.False)
 		case types.I32:
 			ctx.Block.NewRet(constant.NewInt(types.I32, 0))
 		case types.I64:
@@ -81,6 +120,20 @@ func (fn *Function) Codegen(ctx *CodegenContext) (*ir.Func, error) {
 			ctx.Block.NewRet(constant.NewFloat(types.Double, 0))
 		case types.Void: // Is this necessary lol?
 			ctx.Block.NewRet(nil)
+		default:
+			// Arrays and structs come back zeroed
+			ctx.Block.NewRet(constant.NewZeroInitializer(fnIR.Sig.RetType))
+		}
+	}
+
+	// Every way out of the function frees the storage of its runtime sized locals.
+	// The returned value has already been computed by then
+	for _, block := range fnIR.Blocks {
+		if _, ok := block.Term.(*ir.TermRet); ok {
+			ctx.Block = block
+			for _, ptr := range ctx.owned {
+				ctx.freeRuntime(ptr)
+			}
 		}
 	}
 	return fnIR, nil
@@ -106,12 +159,44 @@ func (v *VarDecl) Codegen(ctx *CodegenContext) (value.Value, error) {
 	// Save the alloca in the symbol table
 	ctx.Symbols[v.Var.Name] = alloca
 
+	// Starts out without storage, a declaration inside a loop frees the storage of the last round
+	if ownsStorage(v.Var.Type) {
+		entryBlock.NewStore(constant.NewZeroInitializer(varType), alloca)
+		ctx.freeRuntime(alloca)
+		ctx.owned = append(ctx.owned, alloca)
+	}
+
 	// Generate code for initializer expression if any
 	if v.Value != nil {
-		val, err := v.Value.Codegen(ctx)
+		// Arrays made from another array get their own copy of the elements
+		if needsArrayCopy(v.Var.Type, v.Value) {
+			containerType := v.Var.Type.(*ContainerType)
+			if containerType.IsRuntimeSized {
+				arr, err := ctx.newRuntimeArray(containerType, nil)
+				if err != nil {
+					return nil, err
+				}
+				ctx.Block.NewStore(arr, alloca)
+			}
+			return alloca, ctx.assignArray(alloca, containerType, v.Value)
+		}
+		val, err := ctx.valueOf(v.Value)
 		if err != nil {
 			return nil, err
 		}
+		if ownsStorage(v.Var.Type) && isAddressable(v.Value) {
+			val = ctx.cloneRuntime(val)
+		}
+		// Uninitialized variables start out zeroed, runtime sized ones get zeroed storage
+		if val == nil {
+			val = constant.NewZeroInitializer(varType)
+			if containerType, ok := v.Var.Type.(*ContainerType); ok && containerType.IsRuntimeSized {
+				val, err = ctx.newRuntimeArray(containerType, nil)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
 		// Store the value into the allocated space
 		ctx.Block.NewStore(val, alloca)
 	}
@@ -119,8 +204,56 @@ func (v *VarDecl) Codegen(ctx *CodegenContext) (value.Value, error) {
 	return alloca, nil
 }
 
+func (v *VarDeclList) Codegen(ctx *CodegenContext) (value.Value, error) {
+	for _, decl := range v.Decls {
+		_, err := decl.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// Global variable gen, the initializer has to be a constant
+func (v *VarDecl) CodegenGlobal(ctx *CodegenContext) error {
+	varType, err := llvmTypeFromType(v.Var.Type, ctx)
+	if err != nil {
+		return err
+	}
+	var init constant.Constant = constant.NewZeroInitializer(varType)
+	if v.Value != nil {
+		val, err := v.Value.Codegen(ctx)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			constVal, ok := val.(constant.Constant)
+			if !ok {
+				return NewLangError(NonConstantGlobal, v.Var.Name).At(v.Line, v.Column)
+			}
+			init = constVal
+		}
+	}
+	ctx.Globals[v.Var.Name] = ctx.Module.NewGlobalDef(v.Var.Name, init)
+	return nil
+}
+
 func (r *ReturnStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
-	val, err := r.Value.Codegen(ctx)
+	if needsArrayCopy(r.Type, r.Value) {
+		retType, err := llvmTypeFromType(r.Type, ctx)
+		if err != nil {
+			return nil, err
+		}
+		ptr := ctx.Func.Blocks[0].NewAlloca(retType)
+		err = ctx.assignArray(ptr, r.Type.(*ContainerType), r.Value)
+		if err != nil {
+			return nil, err
+		}
+		val := ctx.Block.NewLoad(ptr)
+		ctx.Block.NewRet(val)
+		return val, nil
+	}
+	val, err := ctx.valueOf(r.Value)
 	if err != nil {
 		return nil, err
 	}
@@ -186,210 +319,1840 @@ func (i *IfStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
 	// something something
 }
 
-func (id *Identifier) Codegen(ctx *CodegenContext) (value.Value, error) {
-	alloca, ok := ctx.Symbols[id.Name]
-	if !ok {
-		// FIXME: Handle this differently
-		return nil, fmt.Errorf("unknown variable %s", id.Name)
+func (w *WhileStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	loopID := ctx.NextLoopID()
+	condBlock := ctx.Func.NewBlock(fmt.Sprintf("while.cond.%d", loopID))
+	bodyBlock := ctx.Func.NewBlock(fmt.Sprintf("while.body.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("while.end.%d", loopID))
+
+	ctx.Block.NewBr(condBlock)
+
+	// Condition is re-evaluated before every iteration
+	ctx.Block = condBlock
+	condVal, err := w.Condition.Codegen(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return ctx.Block.NewLoad(alloca), nil
-}
+	ctx.Block.NewCondBr(condVal, bodyBlock, leaveBlock)
 
-func (n *NumberLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
-	switch n.Type.Name {
-	case PrimitiveN32, PrimitiveZ32:
-		val, err := strconv.ParseInt(n.Value, 10, 32)
+	ctx.Block = bodyBlock
+	ctx.loops = append(ctx.loops, loopTarget{label: w.Label, breakBlock: leaveBlock, continueBlock: condBlock})
+	for _, stmt := range w.Body {
+		_, err := stmt.Codegen(ctx)
 		if err != nil {
 			return nil, err
 		}
-		return constant.NewInt(types.I32, val), nil
-	case PrimitiveN64, PrimitiveZ64:
-		val, err := strconv.ParseInt(n.Value, 10, 64)
+	}
+	ctx.loops = ctx.loops[:len(ctx.loops)-1]
+	if !blockHasTerminator(ctx.Block) {
+		ctx.Block.NewBr(condBlock)
+	}
+
+	ctx.Block = leaveBlock
+	return nil, nil
+}
+
+func (f *ForStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	varType, err := llvmTypeFromType(f.Var.Type, ctx)
+	if err != nil {
+		return nil, err
+	}
+	unsigned := isTypeUnsigned_Type(f.Var.Type)
+
+	// Bounds and step are evaluated once, before the loop starts
+	startVal, err := f.Start.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	endVal, err := f.End.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var stepVal value.Value = constant.NewInt(varType.(*types.IntType), 1)
+	if f.Step != nil {
+		stepVal, err = f.Step.Codegen(ctx)
 		if err != nil {
 			return nil, err
 		}
-		return constant.NewInt(types.I64, val), nil
-	case PrimitiveR32:
-		val, err := strconv.ParseFloat(n.Value, 32)
+	}
+	startVal = ctx.castInt(startVal, varType, unsigned)
+	endVal = ctx.castInt(endVal, varType, unsigned)
+	stepVal = ctx.castInt(stepVal, varType, unsigned)
+
+	// Literal steps are checked by the type checker
+	if _, literal := f.Step.(*NumberLiteral); f.Step != nil && !literal {
+		zero := constant.NewInt(varType.(*types.IntType), 0)
+		positive := ctx.Block.NewICmp(intPred(enum.IPredSGT, enum.IPredNE, unsigned), stepVal, zero)
+		err = ctx.runtimeCheck(positive, ".errstr_loop_step")
 		if err != nil {
 			return nil, err
 		}
-		return constant.NewFloat(types.Float, val), nil
-	case PrimitiveR64:
-		val, err := strconv.ParseFloat(n.Value, 64)
+	}
+
+	alloca := ctx.Func.Blocks[0].NewAlloca(varType)
+	ctx.Block.NewStore(startVal, alloca)
+	defer ctx.bindLoopVar(f.Var.Name, alloca)()
+
+	loopID := ctx.NextLoopID()
+	condBlock := ctx.Func.NewBlock(fmt.Sprintf("for.cond.%d", loopID))
+	bodyBlock := ctx.Func.NewBlock(fmt.Sprintf("for.body.%d", loopID))
+	stepBlock := ctx.Func.NewBlock(fmt.Sprintf("for.step.%d", loopID))
+	nextBlock := ctx.Func.NewBlock(fmt.Sprintf("for.next.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("for.end.%d", loopID))
+	ctx.Block.NewBr(condBlock)
+
+	pred := enum.IPredSLE
+	switch {
+	case f.Down && unsigned:
+		pred = enum.IPredUGE
+	case f.Down:
+		pred = enum.IPredSGE
+	case unsigned:
+		pred = enum.IPredULE
+	}
+	ctx.Block = condBlock
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(pred, ctx.Block.NewLoad(alloca), endVal), bodyBlock, leaveBlock)
+
+	ctx.Block = bodyBlock
+	ctx.loops = append(ctx.loops, loopTarget{label: f.Label, breakBlock: leaveBlock, continueBlock: stepBlock})
+	for _, stmt := range f.Body {
+		_, err := stmt.Codegen(ctx)
 		if err != nil {
 			return nil, err
 		}
-		return constant.NewFloat(types.Double, val), nil
-	default:
-		panic("Không xác định được kiểu dữ liệu của số")
 	}
-}
+	ctx.loops = ctx.loops[:len(ctx.loops)-1]
+	if !blockHasTerminator(ctx.Block) {
+		ctx.Block.NewBr(stepBlock)
+	}
 
-// TODO: Implement the unitialized expression
-func (u *UninitializedExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
-	return nil, nil
-}
+	// Stepping stops when less than a step is left before end, so the counter
+	// never passes end and can't wrap around at the limit of its type
+	ctx.Block = stepBlock
+	current := ctx.Block.NewLoad(alloca)
+	var remaining value.Value = ctx.Block.NewSub(endVal, current)
+	if f.Down {
+		remaining = ctx.Block.NewSub(current, endVal)
+	}
+	inRange := ctx.Block.NewICmp(pred, current, endVal) // The body may have moved the counter
+	roomLeft := ctx.Block.NewICmp(enum.IPredUGE, remaining, stepVal)
+	ctx.Block.NewCondBr(ctx.Block.NewAnd(inRange, roomLeft), nextBlock, leaveBlock)
 
-// TODO: Implement the struct literal codegen
-func (s *StructLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	ctx.Block = nextBlock
+	if f.Down {
+		ctx.Block.NewStore(ctx.Block.NewSub(current, stepVal), alloca)
+	} else {
+		ctx.Block.NewStore(ctx.Block.NewAdd(current, stepVal), alloca)
+	}
+	ctx.Block.NewBr(bodyBlock)
+
+	ctx.Block = leaveBlock
 	return nil, nil
 }
 
-func (a *ArrayLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
-	containerType, ok := a.Type.(*ContainerType)
-	if !ok {
-		// Most likely won't happen but still check
-		return nil, NewLangError(TypeMismatch, a.Type.String(), "kiểu thùng chứa đựng (mảng)")
-	}
-
-	/*
-		// Assuming all the elements have already type-checked to the same Type (hopefully)
-		elemType, err := llvmTypeFromType(containerType.ElementType, ctx)
-		if err != nil {
-			return nil, err
+// The loop variable shadows any outer variable with the same name until the returned func is called
+func (ctx *CodegenContext) bindLoopVar(name string, alloca value.Value) func() {
+	outer, shadowed := ctx.Symbols[name]
+	ctx.Symbols[name] = alloca
+	return func() {
+		if shadowed {
+			ctx.Symbols[name] = outer
+		} else {
+			delete(ctx.Symbols, name)
 		}
-	*/
-
-	// Extract user-defined bounds
-	if len(containerType.Bounds) != 2 {
-		line, col := a.Pos()
-		return nil, fmt.Errorf("[Dòng %d, Cột %d] mảng phải có đúng 2 giới hạn (sàn và trần) thay vì %d", line, col, len(containerType.Bounds)) // TODO: Make a new error type
 	}
+}
 
-	lowerBoundVal, err := containerType.Bounds[0].Codegen(ctx)
+// Elements are read straight from storage, the loop itself stays within the bounds
+func (f *ForEachStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	source, err := ctx.forEachSource(f.Collection)
 	if err != nil {
 		return nil, err
 	}
-	upperBoundVal, err := containerType.Bounds[1].Codegen(ctx)
+	varType, err := llvmTypeFromType(f.Var.Type, ctx)
 	if err != nil {
 		return nil, err
 	}
+	counter := ctx.Func.Blocks[0].NewAlloca(types.I64)
+	ctx.Block.NewStore(constant.NewInt(types.I64, 0), counter)
+	alloca := ctx.Func.Blocks[0].NewAlloca(varType)
+	defer ctx.bindLoopVar(f.Var.Name, alloca)()
+	var indexAlloca value.Value
+	if f.Index != nil {
+		indexAlloca = ctx.Func.Blocks[0].NewAlloca(types.I64)
+		defer ctx.bindLoopVar(f.Index.Name, indexAlloca)()
+	}
 
-	lowerConst, lok := lowerBoundVal.(*constant.Int)
-	upperConst, uok := upperBoundVal.(*constant.Int)
-	if !lok || !uok {
-		return nil, errors.New("giới hạn mảng phải là số nguyên")
+	loopID := ctx.NextLoopID()
+	condBlock := ctx.Func.NewBlock(fmt.Sprintf("foreach.cond.%d", loopID))
+	bodyBlock := ctx.Func.NewBlock(fmt.Sprintf("foreach.body.%d", loopID))
+	stepBlock := ctx.Func.NewBlock(fmt.Sprintf("foreach.step.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("foreach.end.%d", loopID))
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = condBlock
+	k := ctx.Block.NewLoad(counter)
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredSLT, k, source.count()), bodyBlock, leaveBlock)
+
+	ctx.Block = bodyBlock
+	ctx.Block.NewStore(source.element(k), alloca)
+	if indexAlloca != nil {
+		ctx.Block.NewStore(ctx.Block.NewAdd(source.lower(), k), indexAlloca)
+	}
+	ctx.loops = append(ctx.loops, loopTarget{label: f.Label, breakBlock: leaveBlock, continueBlock: stepBlock})
+	for _, stmt := range f.Body {
+		_, err := stmt.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx.loops = ctx.loops[:len(ctx.loops)-1]
+	if !blockHasTerminator(ctx.Block) {
+		ctx.Block.NewBr(stepBlock)
 	}
 
-	lowerBound := lowerConst.X.Int64()
-	upperBound := upperConst.X.Int64()
-	if lowerBound > upperBound {
-		return nil, fmt.Errorf("giới hạn sàn (%d) cao hơn giới hạn trần (%d)", lowerBound, upperBound)
+	ctx.Block = stepBlock
+	ctx.Block.NewStore(ctx.Block.NewAdd(ctx.Block.NewLoad(counter), constant.NewInt(types.I64, 1)), counter)
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = leaveBlock
+	return nil, nil
+}
+
+// What a "với mỗi" loop goes through: count elements, the first one at index lower.
+// Everything is emitted in the current block, so it can be read again on every iteration
+type forEachSource struct {
+	lower   func() value.Value
+	count   func() value.Value
+	element func(k value.Value) value.Value // Element k counting from 0
+}
+
+func (ctx *CodegenContext) forEachSource(collection Expression) (forEachSource, error) {
+	containerType := getExprType(collection).(*ContainerType)
+	elemType, err := llvmTypeFromType(containerType.ElementType, ctx)
+	if err != nil {
+		return forEachSource{}, err
 	}
 
-	n := upperBound - lowerBound + 1
-	if int64(len(a.Elements)) != n {
-		return nil, fmt.Errorf("mong đợi %d phần tử cho giới hạn [%d..%d], được %d", n, lowerBound, upperBound, len(a.Elements))
+	// Growing the array inside the loop may move its elements, so the data pointer is read every time
+	if containerType.IsDynamic {
+		ptr, err := ctx.storageOf(collection)
+		if err != nil {
+			return forEachSource{}, err
+		}
+		zero := constant.NewInt(types.I32, 0)
+		count := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(ptr, zero, constant.NewInt(types.I32, 1)))
+		return forEachSource{
+			lower: func() value.Value { return constant.NewInt(types.I64, 0) },
+			count: func() value.Value { return count },
+			element: func(k value.Value) value.Value {
+				data := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(ptr, zero, zero))
+				return ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, k))
+			},
+		}, nil
 	}
 
-	values := make([]constant.Constant, len(a.Elements))
-	for i, elem := range a.Elements {
-		val, err := elem.Codegen(ctx)
+	// Otherwise everything is laid out row by row from data
+	ptr, err := ctx.storageOf(collection)
+	if err != nil {
+		return forEachSource{}, err
+	}
+	var layout func() (data, lower, upper, rowLower, rowUpper value.Value)
+	if containerType.IsRuntimeSized {
+		// Assigning to the array inside the loop replaces its storage, so it is read every time
+		layout = func() (data, lower, upper, rowLower, rowUpper value.Value) {
+			arr := ctx.Block.NewLoad(ptr)
+			data, lower, upper = ctx.Block.NewExtractValue(arr, 0), ctx.Block.NewExtractValue(arr, 1), ctx.Block.NewExtractValue(arr, 2)
+			if containerType.Kind == ContainerMatrix {
+				rowLower, rowUpper = ctx.Block.NewExtractValue(arr, 3), ctx.Block.NewExtractValue(arr, 4)
+			}
+			return
+		}
+	} else {
+		bounds, err := constBounds(containerType, ctx)
 		if err != nil {
-			return nil, err
+			return forEachSource{}, err
 		}
-		constVal, ok := val.(constant.Constant)
-		if !ok {
-			line, col := a.Pos()
-			return nil, fmt.Errorf("[Dòng %d, Cột %d]Phần tử của mảng phải là hằng số", line, col)
+		layout = func() (data, lower, upper, rowLower, rowUpper value.Value) {
+			data = ctx.Block.NewBitCast(ptr, types.NewPointer(elemType))
+			lower, upper = constant.NewInt(types.I64, bounds[0][0]), constant.NewInt(types.I64, bounds[0][1])
+			if containerType.Kind == ContainerMatrix {
+				rowLower, rowUpper = constant.NewInt(types.I64, bounds[1][0]), constant.NewInt(types.I64, bounds[1][1])
+			}
+			return
 		}
-		values[i] = constVal
 	}
 
-	return constant.NewArray(values...), nil
+	source := forEachSource{
+		lower: func() value.Value {
+			_, lower, _, _, _ := layout()
+			return lower
+		},
+		count: func() value.Value {
+			_, lower, upper, _, _ := layout()
+			return ctx.Block.NewAdd(ctx.Block.NewSub(upper, lower), constant.NewInt(types.I64, 1))
+		},
+	}
+	if containerType.Kind != ContainerMatrix {
+		source.element = func(k value.Value) value.Value {
+			data, _, _, _, _ := layout()
+			return ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, k))
+		}
+		return source, nil
+	}
+	// A row is an open array starting at its first element
+	source.element = func(k value.Value) value.Value {
+		data, _, _, rowLower, rowUpper := layout()
+		cols := ctx.Block.NewAdd(ctx.Block.NewSub(rowUpper, rowLower), constant.NewInt(types.I64, 1))
+		var row value.Value = constant.NewUndef(runtimeArrayType(elemType, 1))
+		row = ctx.Block.NewInsertValue(row, ctx.Block.NewGetElementPtr(data, ctx.Block.NewMul(k, cols)), 0)
+		row = ctx.Block.NewInsertValue(row, rowLower, 1)
+		return ctx.Block.NewInsertValue(row, rowUpper, 2)
+	}
+	return source, nil
 }
 
-func (b *BinaryExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
-	leftVal, err := b.Left.Codegen(ctx)
+// Address of a variable, or of a temporary copy when expr isn't one
+func (ctx *CodegenContext) storageOf(expr Expression) (value.Value, error) {
+	switch expr.(type) {
+	case *Identifier, *IndexExpr, *FieldExpr:
+		return addressOf(expr, ctx)
+	}
+	val, err := expr.Codegen(ctx)
 	if err != nil {
 		return nil, err
 	}
-	rightVal, err := b.Right.Codegen(ctx)
+	ptr := ctx.Block.NewAlloca(val.Type())
+	ctx.Block.NewStore(val, ptr)
+	return ptr, nil
+}
+
+func (b *BreakStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	target, err := ctx.findLoop(b.Label, b.Line, b.Column)
 	if err != nil {
 		return nil, err
 	}
+	ctx.jumpOut(target.breakBlock)
+	return nil, nil
+}
 
-	switch b.Operator {
-	case SymbolPlus:
-		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
-			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
-			return ctx.Block.NewFAdd(leftVal, rightVal), nil
+func (c *ContinueStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	target, err := ctx.findLoop(c.Label, c.Line, c.Column)
+	if err != nil {
+		return nil, err
+	}
+	ctx.jumpOut(target.continueBlock)
+	return nil, nil
+}
+
+// Finds the innermost loop, or the innermost loop with the given label
+func (ctx *CodegenContext) findLoop(label string, line, col int) (loopTarget, error) {
+	for i := len(ctx.loops) - 1; i >= 0; i-- {
+		if label == "" || ctx.loops[i].label == label {
+			return ctx.loops[i], nil
 		}
-		return ctx.Block.NewAdd(leftVal, rightVal), nil
-	case SymbolMinus:
-		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
-			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
-			return ctx.Block.NewFSub(leftVal, rightVal), nil
+	}
+	if label == "" {
+		return loopTarget{}, NewLangError(LoopControlOutsideLoop, KeywordDung).At(line, col)
+	}
+	return loopTarget{}, NewLangError(UnknownLoopLabel, label).At(line, col)
+}
+
+// Branches to target, statements after the jump go into an unreachable block
+func (ctx *CodegenContext) jumpOut(target *ir.Block) {
+	ctx.Block.NewBr(target)
+	ctx.Block = ctx.Func.NewBlock(fmt.Sprintf("dead.%d", ctx.NextFlowID()))
+}
+
+func (a *AssignStmt) Codegen(ctx *CodegenContext) (value.Value, error) {
+	ptr, err := addressOf(a.Target, ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetType := getExprType(a.Target)
+	if needsArrayCopy(targetType, a.Value) {
+		return nil, ctx.assignArray(ptr, targetType.(*ContainerType), a.Value)
+	}
+	val, err := ctx.valueOf(a.Value)
+	if err != nil {
+		return nil, err
+	}
+	// New storage replaces the old one, which is freed
+	if ownsStorage(targetType) {
+		if isAddressable(a.Value) {
+			val = ctx.cloneRuntime(val)
 		}
-		return ctx.Block.NewSub(leftVal, rightVal), nil
-	case SymbolAsterisk:
-		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
-			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
-			return ctx.Block.NewFMul(leftVal, rightVal), nil
+		ctx.freeRuntime(ptr)
+	}
+	ctx.Block.NewStore(val, ptr)
+	return nil, nil
+}
+
+// Runtime sized locals own their storage, anything else sized at runtime is a view of
+// storage owned elsewhere. Values read from another variable are cloned, so no two
+// variables share storage
+func ownsStorage(typ Type) bool {
+	container, ok := typ.(*ContainerType)
+	return ok && container.IsRuntimeSized && !container.IsOpen
+}
+
+func isAddressable(expr Expression) bool {
+	switch e := expr.(type) {
+	case *Identifier, *FieldExpr:
+		return true
+	case *IndexExpr:
+		return e.SliceEnd == nil
+	}
+	return false
+}
+
+// Frees the storage of the runtime sized container at ptr
+func (ctx *CodegenContext) freeRuntime(ptr value.Value) {
+	data := ctx.Block.NewExtractValue(ctx.Block.NewLoad(ptr), 0)
+	ctx.Block.NewCall(findFunction(ctx.Module, "free"), ctx.Block.NewBitCast(data, types.I8Ptr))
+}
+
+// Same bounds as arr, with storage of its own
+func (ctx *CodegenContext) cloneRuntime(arr value.Value) value.Value {
+	data := ctx.Block.NewExtractValue(arr, 0)
+	var count value.Value = constant.NewInt(types.I64, 1)
+	for d := range (len(arr.Type().(*types.StructType).Fields) - 1) / 2 {
+		count = ctx.Block.NewMul(count, ctx.runtimeLength(arr, d))
+	}
+	size := ctx.Block.NewMul(count, sizeOf(data.Type().(*types.PointerType).ElemType))
+	raw := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), size)
+	ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), raw, ctx.Block.NewBitCast(data, types.I8Ptr), size)
+	return ctx.Block.NewInsertValue(arr, ctx.Block.NewBitCast(raw, data.Type()), 0)
+}
+
+// Value of expr for storing somewhere else. Growable arrays and maps read from a variable,
+// also inside structs and fixed arrays, are copied so the two never share storage
+func (ctx *CodegenContext) valueOf(expr Expression) (value.Value, error) {
+	val, err := expr.Codegen(ctx)
+	if err != nil || !isAddressable(expr) {
+		return val, err
+	}
+	return ctx.deepCopy(val, getExprType(expr))
+}
+
+// Whether a value of typ refers to storage on the heap that a copy has to duplicate.
+// Runtime sized containers are handled by assignArray and cloneRuntime
+func needsDeepCopy(typ Type) bool {
+	switch t := typ.(type) {
+	case *ContainerType:
+		if t.Kind == ContainerHashMap || t.IsDynamic {
+			return true
 		}
-		return ctx.Block.NewMul(leftVal, rightVal), nil
-	case SymbolSlash:
-		// For now treat unsigned as signed
-		if leftVal.Type().Equal(types.I32) && rightVal.Type().Equal(types.I32) || leftVal.Type().Equal(types.I64) && rightVal.Type().Equal(types.I64) {
-			return ctx.Block.NewSDiv(leftVal, rightVal), nil
+		return !t.IsRuntimeSized && needsDeepCopy(t.ElementType)
+	case *StructType:
+		for _, name := range t.Order {
+			if needsDeepCopy(t.Fields[name]) {
+				return true
+			}
 		}
-		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) && (rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
-			return ctx.Block.NewFDiv(leftVal, rightVal), nil
+	}
+	return false
+}
+
+// Copy of val with storage of its own, see needsDeepCopy
+func (ctx *CodegenContext) deepCopy(val value.Value, typ Type) (value.Value, error) {
+	if !needsDeepCopy(typ) {
+		return val, nil
+	}
+	if st, ok := typ.(*StructType); ok {
+		for i, name := range st.Order {
+			field, err := ctx.deepCopy(ctx.Block.NewExtractValue(val, uint64(i)), st.Fields[name])
+			if err != nil {
+				return nil, err
+			}
+			val = ctx.Block.NewInsertValue(val, field, uint64(i))
 		}
-		return nil, fmt.Errorf("gặp sự cố khi thực hiện phép toán")
-	case SymbolLess:
-		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredSLT, leftVal, rightVal), nil
-		} else if canFCmp(leftVal, rightVal) {
-			return ctx.Block.NewFCmp(enum.FPredOLT, leftVal, rightVal), nil
+		return val, nil
+	}
+
+	container := typ.(*ContainerType)
+	elemType, err := llvmTypeFromType(container.ElementType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	ptr := ctx.Func.Blocks[0].NewAlloca(val.Type())
+	ctx.Block.NewStore(val, ptr)
+	switch {
+	case container.Kind == ContainerHashMap:
+		ctx.Block.NewStore(ctx.Block.NewCall(ctx.mapCopyFunc(), ptr, sizeOf(elemType)), ptr)
+		if needsDeepCopy(container.ElementType) {
+			err = ctx.deepCopyMapValues(ptr, container.ElementType, elemType)
+		}
+	case container.IsDynamic:
+		data := ctx.Block.NewExtractValue(val, 0)
+		length := ctx.Block.NewExtractValue(val, 1)
+		size := ctx.Block.NewMul(length, sizeOf(elemType))
+		raw := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), size)
+		ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), raw, ctx.Block.NewBitCast(data, types.I8Ptr), size)
+		var arr value.Value = ctx.Block.NewInsertValue(val, ctx.Block.NewBitCast(raw, data.Type()), 0)
+		ctx.Block.NewStore(ctx.Block.NewInsertValue(arr, length, 2), ptr)
+		err = ctx.deepCopyElements(ctx.Block.NewBitCast(raw, data.Type()), length, container.ElementType)
+	default:
+		arrType := val.Type().(*types.ArrayType)
+		data := ctx.Block.NewBitCast(ptr, types.NewPointer(arrType.ElemType))
+		err = ctx.deepCopyElements(data, constant.NewInt(types.I64, int64(arrType.Len)), container.ElementType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Block.NewLoad(ptr), nil
+}
+
+// Replaces the n elements at data with deep copies of themselves
+func (ctx *CodegenContext) deepCopyElements(data, n value.Value, elemType Type) error {
+	if !needsDeepCopy(elemType) {
+		return nil
+	}
+	return ctx.emitLoop(n, func(i value.Value) error {
+		elemPtr := ctx.Block.NewGetElementPtr(data, i)
+		copied, err := ctx.deepCopy(ctx.Block.NewLoad(elemPtr), elemType)
+		if err != nil {
+			return err
+		}
+		ctx.Block.NewStore(copied, elemPtr)
+		return nil
+	})
+}
+
+// Replaces the values in the full slots of the map at mapPtr with deep copies of themselves
+func (ctx *CodegenContext) deepCopyMapValues(mapPtr value.Value, valueType Type, llvmValueType types.Type) error {
+	capacity := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapCapacity))
+	return ctx.emitLoop(capacity, func(i value.Value) error {
+		flowID := ctx.NextFlowID()
+		copyBlock := ctx.Func.NewBlock(fmt.Sprintf("copy.value.%d", flowID))
+		nextBlock := ctx.Func.NewBlock(fmt.Sprintf("copy.next.%d", flowID))
+		states := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapStates))
+		state := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(states, i))
+		ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, state, constant.NewInt(types.I8, slotFull)), copyBlock, nextBlock)
+
+		ctx.Block = copyBlock
+		err := ctx.deepCopyElements(ctx.mapValueAt(mapPtr, i, llvmValueType), constant.NewInt(types.I64, 1), valueType)
+		if err != nil {
+			return err
+		}
+		ctx.Block.NewBr(nextBlock)
+
+		ctx.Block = nextBlock
+		return nil
+	})
+}
+
+// Assigning an array copies its elements, unless it's a fixed size array of the same
+// kind (copied as a value anyway) or a new array nobody else refers to
+func needsArrayCopy(target Type, val Expression) bool {
+	to, ok1 := target.(*ContainerType)
+	from, ok2 := getExprType(val).(*ContainerType)
+	if !ok1 || !ok2 || to.Kind == ContainerHashMap || from.Kind != to.Kind || to.IsOpen {
+		return false
+	}
+	switch val.(type) {
+	case *ArrayLiteral, *UninitializedExpr:
+		return false
+	case *CallExpr:
+		if from.IsDynamic == to.IsDynamic && from.IsRuntimeSized == to.IsRuntimeSized {
+			return false
+		}
+	}
+	if to.IsDynamic || to.IsRuntimeSized {
+		return true
+	}
+	return from.IsDynamic || from.IsRuntimeSized
+}
+
+// Copies the elements of val into the array at ptr. Growable arrays get new storage,
+// the others keep theirs and must have as many elements as val
+func (ctx *CodegenContext) assignArray(ptr value.Value, to *ContainerType, val Expression) error {
+	if to.Kind == ContainerMatrix {
+		return ctx.assignMatrix(ptr, to, val)
+	}
+	src, err := ctx.openArray(val)
+	if err != nil {
+		return err
+	}
+	srcData := ctx.Block.NewExtractValue(src, 0)
+	length := ctx.runtimeLength(src, 0)
+	size := ctx.Block.NewMul(length, sizeOf(srcData.Type().(*types.PointerType).ElemType))
+	i8Ptr := func(ptr value.Value) value.Value { return ctx.Block.NewBitCast(ptr, types.I8Ptr) }
+
+	if to.IsDynamic {
+		raw := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), size)
+		ctx.Block.NewCall(findFunction(ctx.Module, "memmove"), raw, i8Ptr(srcData), size)
+		var arr value.Value = constant.NewUndef(dynamicArrayType(srcData.Type().(*types.PointerType).ElemType))
+		arr = ctx.Block.NewInsertValue(arr, ctx.Block.NewBitCast(raw, srcData.Type()), 0)
+		arr = ctx.Block.NewInsertValue(arr, length, 1)
+		ctx.Block.NewStore(ctx.Block.NewInsertValue(arr, length, 2), ptr)
+		return ctx.deepCopyElements(ctx.Block.NewBitCast(raw, srcData.Type()), length, to.ElementType)
+	}
+
+	var dstData, dstLength value.Value
+	if to.IsRuntimeSized {
+		dst := ctx.Block.NewLoad(ptr)
+		dstData, dstLength = ctx.Block.NewExtractValue(dst, 0), ctx.runtimeLength(dst, 0)
+	} else {
+		arrType := ptr.Type().(*types.PointerType).ElemType.(*types.ArrayType)
+		dstData, dstLength = ctx.Block.NewBitCast(ptr, srcData.Type()), constant.NewInt(types.I64, int64(arrType.Len))
+	}
+	err = ctx.runtimeCheck(ctx.Block.NewICmp(enum.IPredEQ, dstLength, length), ".errstr_array_size")
+	if err != nil {
+		return err
+	}
+	// The two may overlap, like a := a[2..4] copied from a slice of itself
+	ctx.Block.NewCall(findFunction(ctx.Module, "memmove"), i8Ptr(dstData), i8Ptr(srcData), size)
+	return ctx.deepCopyElements(dstData, length, to.ElementType)
+}
+
+// Matrices keep their storage, val must have as many rows and columns
+func (ctx *CodegenContext) assignMatrix(ptr value.Value, to *ContainerType, val Expression) error {
+	srcVal, err := val.Codegen(ctx)
+	if err != nil {
+		return err
+	}
+	src := ctx.viewMatrix(srcVal)
+	var dst matrixView
+	if to.IsRuntimeSized {
+		dst = ctx.viewMatrix(ctx.Block.NewLoad(ptr))
+	} else {
+		dst = ctx.newMatrixView(ptr)
+	}
+	err = ctx.checkSize(dst.rows, src.rows)
+	if err != nil {
+		return err
+	}
+	err = ctx.checkSize(dst.cols, src.cols)
+	if err != nil {
+		return err
+	}
+	count := ctx.Block.NewMul(src.rows, src.cols)
+	size := ctx.Block.NewMul(count, sizeOf(src.data.Type().(*types.PointerType).ElemType))
+	// The two may be the same matrix, like a := a
+	ctx.Block.NewCall(findFunction(ctx.Module, "memmove"), ctx.Block.NewBitCast(dst.data, types.I8Ptr), ctx.Block.NewBitCast(src.data, types.I8Ptr), size)
+	return ctx.deepCopyElements(dst.data, count, to.ElementType)
+}
+
+func (id *Identifier) Codegen(ctx *CodegenContext) (value.Value, error) {
+	alloca, err := addressOf(id, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Block.NewLoad(alloca), nil
+}
+
+func (n *NumberLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	switch n.Type.Name {
+	case PrimitiveZ32:
+		val, err := strconv.ParseInt(n.Value, 10, 32)
+		if err != nil {
+			return nil, NewLangError(LiteralOutOfRange, n.Value, n.Type.Name).At(n.Line, n.Column)
+		}
+		return constant.NewInt(types.I32, val), nil
+	case PrimitiveZ64:
+		val, err := strconv.ParseInt(n.Value, 10, 64)
+		if err != nil {
+			return nil, NewLangError(LiteralOutOfRange, n.Value, n.Type.Name).At(n.Line, n.Column)
+		}
+		return constant.NewInt(types.I64, val), nil
+	case PrimitiveN32:
+		// Same bits as the unsigned value, LLVM integers have no sign
+		val, err := strconv.ParseUint(n.Value, 10, 32)
+		if err != nil {
+			return nil, NewLangError(LiteralOutOfRange, n.Value, n.Type.Name).At(n.Line, n.Column)
+		}
+		return constant.NewInt(types.I32, int64(int32(uint32(val)))), nil
+	case PrimitiveN64:
+		val, err := strconv.ParseUint(n.Value, 10, 64)
+		if err != nil {
+			return nil, NewLangError(LiteralOutOfRange, n.Value, n.Type.Name).At(n.Line, n.Column)
+		}
+		return constant.NewInt(types.I64, int64(val)), nil
+	case PrimitiveR32:
+		val, err := strconv.ParseFloat(n.Value, 32)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewFloat(types.Float, val), nil
+	case PrimitiveR64:
+		val, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewFloat(types.Double, val), nil
+	default:
+		panic("Không xác định được kiểu dữ liệu của số")
+	}
+}
+
+func (c *CharLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	typ, err := llvmTypeFromPrimitive(&c.Type)
+	if err != nil {
+		return nil, err
+	}
+	return constant.NewInt(typ.(*types.IntType), int64(c.Value)), nil
+}
+
+func (b *BooleanLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	return constant.NewBool(b.Value), nil
+}
+
+func (s *StringLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	return ctx.constString(s.Value), nil
+}
+
+// Pointer to a null terminated copy of str. Identical strings share one private global
+func (ctx *CodegenContext) constString(str string) constant.Constant {
+	global, ok := ctx.strings[str]
+	if !ok {
+		global = ctx.GetOrCreateGlobalString(fmt.Sprintf(".str.%d", ctx.NextStrID()), str+"\x00")
+		ctx.strings[str] = global
+	}
+	zero := constant.NewInt(types.I64, 0)
+	// Constant expression so it can also initialize globals
+	return constant.NewGetElementPtr(global, zero, zero)
+}
+
+// TODO: Implement the unitialized expression
+func (u *UninitializedExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	return nil, nil
+}
+
+func (s *StructLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	st, ok := s.Type.(*StructType)
+	if !ok {
+		return nil, NewLangError(UnknownStructType, s.StructName).At(s.Line, s.Column)
+	}
+	llvmType, err := llvmTypeFromType(st, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields are evaluated in declaration order, missing ones are zeroed
+	values := make([]value.Value, len(st.Order))
+	allConst := true
+	for i, name := range st.Order {
+		expr, ok := s.Fields[name]
+		if !ok {
+			fieldType, err := llvmTypeFromType(st.Fields[name], ctx)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = constant.NewZeroInitializer(fieldType)
+			continue
+		}
+		val, err := ctx.valueOf(expr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := val.(constant.Constant); !ok {
+			allConst = false
+		}
+		values[i] = val
+	}
+
+	// Constant literals can also initialize globals
+	if allConst {
+		fields := make([]constant.Constant, len(values))
+		for i, val := range values {
+			fields[i] = val.(constant.Constant)
+		}
+		structConst := constant.NewStruct(fields...)
+		structConst.Typ = llvmType.(*types.StructType) // Use the named type instead of a literal one
+		return structConst, nil
+	}
+	var agg value.Value = constant.NewZeroInitializer(llvmType)
+	for i, val := range values {
+		agg = ctx.Block.NewInsertValue(agg, val, uint64(i))
+	}
+	return agg, nil
+}
+
+func (f *FieldExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	switch f.Object.(type) {
+	case *Identifier, *IndexExpr, *FieldExpr:
+		ptr, err := addressOf(f, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.Block.NewLoad(ptr), nil
+	default:
+		// Temporary values like call results are read directly
+		val, err := f.Object.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.Block.NewExtractValue(val, uint64(f.Index)), nil
+	}
+}
+
+func (a *ArrayLiteral) Codegen(ctx *CodegenContext) (value.Value, error) {
+	containerType, ok := a.Type.(*ContainerType)
+	if !ok {
+		// Most likely won't happen but still check
+		return nil, NewLangError(TypeMismatch, a.Type.String(), "kiểu thùng chứa đựng (mảng)")
+	}
+
+	/*
+		// Assuming all the elements have already type-checked to the same Type (hopefully)
+		elemType, err := llvmTypeFromType(containerType.ElementType, ctx)
+		if err != nil {
+			return nil, err
+		}
+	*/
+
+	if containerType.IsDynamic {
+		return a.codegenDynamic(containerType, ctx)
+	}
+	if containerType.IsRuntimeSized {
+		return a.codegenRuntimeSized(containerType, ctx)
+	}
+
+	llvmType, err := llvmTypeFromType(containerType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rows of a matrix literal are array literals themselves
+	n := llvmType.(*types.ArrayType).Len
+	if uint64(len(a.Elements)) != n {
+		return nil, fmt.Errorf("mong đợi %d phần tử, được %d", n, len(a.Elements))
+	}
+	values, err := a.elementValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.arrayValue(values, a.Line, a.Column)
+}
+
+func (a *ArrayLiteral) elementValues(ctx *CodegenContext) ([]value.Value, error) {
+	values := make([]value.Value, len(a.Elements))
+	for i, elem := range a.Elements {
+		val, err := ctx.valueOf(elem)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
+}
+
+// An LLVM array holding values: a constant when they all are, otherwise filled in with insertvalue
+func (ctx *CodegenContext) arrayValue(values []value.Value, line, col int) (value.Value, error) {
+	consts := make([]constant.Constant, len(values))
+	for i, val := range values {
+		constVal, ok := val.(constant.Constant)
+		if !ok {
+			consts = nil
+			break
+		}
+		consts[i] = constVal
+	}
+	if consts != nil {
+		return constant.NewArray(consts...), nil
+	}
+
+	// Globals are set up before anything runs
+	if ctx.Block == nil {
+		return nil, fmt.Errorf("[Dòng %d, Cột %d]Phần tử của mảng phải là hằng số", line, col)
+	}
+	var arr value.Value = constant.NewUndef(types.NewArray(uint64(len(values)), values[0].Type()))
+	for i, val := range values {
+		arr = ctx.Block.NewInsertValue(arr, val, uint64(i))
+	}
+	return arr, nil
+}
+
+// Literal for a runtime sized container: storage comes from the bounds, which have to
+// match the shape of the literal
+func (a *ArrayLiteral) codegenRuntimeSized(containerType *ContainerType, ctx *CodegenContext) (value.Value, error) {
+	arr, err := ctx.newRuntimeArray(containerType, nil)
+	if err != nil {
+		return nil, err
+	}
+	elements := a.Elements
+	shape := []int{len(a.Elements)}
+	if containerType.Dimensions == 2 {
+		// Stored row by row
+		elements = nil
+		for _, row := range a.Elements {
+			elements = append(elements, row.(*ArrayLiteral).Elements...)
+		}
+		cols := 0
+		if len(a.Elements) > 0 {
+			cols = len(a.Elements[0].(*ArrayLiteral).Elements)
+		}
+		shape = append(shape, cols)
+	}
+	for d, n := range shape {
+		sameSize := ctx.Block.NewICmp(enum.IPredEQ, ctx.runtimeLength(arr, d), constant.NewInt(types.I64, int64(n)))
+		err := ctx.runtimeCheck(sameSize, ".errstr_array_size")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := ctx.Block.NewExtractValue(arr, 0)
+	for k, elem := range elements {
+		val, err := elem.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ctx.Block.NewStore(val, ctx.Block.NewGetElementPtr(data, constant.NewInt(types.I64, int64(k))))
+	}
+	return arr, nil
+}
+
+// Allocates zeroed heap storage for a runtime sized container from its bounds. When lengths
+// is given, only the lower bounds are evaluated and lengths sizes each dimension
+func (ctx *CodegenContext) newRuntimeArray(typ *ContainerType, lengths []value.Value) (value.Value, error) {
+	elemType, err := llvmTypeFromType(typ.ElementType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	one := constant.NewInt(types.I64, 1)
+	var arr value.Value = constant.NewUndef(runtimeArrayType(elemType, typ.Dimensions))
+	var count value.Value = one
+	for d := range typ.Dimensions {
+		lower, err := ctx.boundValue(typ.Bounds[2*d])
+		if err != nil {
+			return nil, err
+		}
+		var upper value.Value
+		if lengths != nil {
+			upper = ctx.Block.NewSub(ctx.Block.NewAdd(lower, lengths[d]), one)
+		} else {
+			upper, err = ctx.boundValue(typ.Bounds[2*d+1])
+			if err != nil {
+				return nil, err
+			}
+			// An empty range like 1..0 is fine, one going backwards isn't
+			err = ctx.runtimeCheck(ctx.Block.NewICmp(enum.IPredSLE, lower, ctx.Block.NewAdd(upper, one)), ".errstr_array_bounds")
+			if err != nil {
+				return nil, err
+			}
+		}
+		arr = ctx.Block.NewInsertValue(arr, lower, uint64(1+2*d))
+		arr = ctx.Block.NewInsertValue(arr, upper, uint64(2+2*d))
+		count = ctx.Block.NewMul(count, ctx.Block.NewAdd(ctx.Block.NewSub(upper, lower), one))
+	}
+	raw := ctx.Block.NewCall(findFunction(ctx.Module, "calloc"), count, sizeOf(elemType))
+	return ctx.Block.NewInsertValue(arr, ctx.Block.NewBitCast(raw, types.NewPointer(elemType)), 0), nil
+}
+
+// A bound evaluated as an i64
+func (ctx *CodegenContext) boundValue(bound Expression) (value.Value, error) {
+	val, err := bound.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.castInt(val, types.I64, isTypeUnsigned_Type(getExprType(bound))), nil
+}
+
+// Number of elements in dimension d of a runtime sized container
+func (ctx *CodegenContext) runtimeLength(arr value.Value, d int) value.Value {
+	lower := ctx.Block.NewExtractValue(arr, uint64(1+2*d))
+	upper := ctx.Block.NewExtractValue(arr, uint64(2+2*d))
+	return ctx.Block.NewAdd(ctx.Block.NewSub(upper, lower), constant.NewInt(types.I64, 1))
+}
+
+// Literal for a dynamic array: the elements are copied to the heap so the array can grow
+func (a *ArrayLiteral) codegenDynamic(containerType *ContainerType, ctx *CodegenContext) (value.Value, error) {
+	elemType, err := llvmTypeFromType(containerType.ElementType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	arrType := dynamicArrayType(elemType)
+	n := int64(len(a.Elements))
+	if n == 0 {
+		return constant.NewZeroInitializer(arrType), nil
+	}
+
+	values, err := a.elementValues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := ctx.arrayValue(values, a.Line, a.Column)
+	if err != nil {
+		return nil, err
+	}
+	length := constant.NewInt(types.I64, n)
+
+	// Globals have no function to call malloc from, so they point at static storage
+	if ctx.Block == nil {
+		storage := ctx.Module.NewGlobalDef(fmt.Sprintf(".arr.%d", ctx.NextArrID()), elems.(constant.Constant))
+		storage.Linkage = enum.LinkagePrivate
+		zero := constant.NewInt(types.I64, 0)
+		data := constant.NewGetElementPtr(storage, zero, zero)
+		arr := constant.NewStruct(data, length, zero)
+		arr.Typ = arrType
+		return arr, nil
+	}
+
+	malloc := findFunction(ctx.Module, "malloc")
+	raw := ctx.Block.NewCall(malloc, constant.NewMul(sizeOf(elemType), length))
+	ctx.Block.NewStore(elems, ctx.Block.NewBitCast(raw, types.NewPointer(elems.Type())))
+	data := ctx.Block.NewBitCast(raw, types.NewPointer(elemType))
+	var arr value.Value = constant.NewUndef(arrType)
+	arr = ctx.Block.NewInsertValue(arr, data, 0)
+	arr = ctx.Block.NewInsertValue(arr, length, 1)
+	arr = ctx.Block.NewInsertValue(arr, length, 2)
+	return arr, nil
+}
+
+func (b *BinaryExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	if _, ok := getExprType(b.Left).(*ContainerType); ok && (b.Operator == SymbolEqual || b.Operator == SymbolNotEqual) {
+		return b.codegenContainerEquality(ctx)
+	}
+	leftVal, err := b.Left.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b.Operator == KeywordVa || b.Operator == KeywordHoac {
+		return b.codegenShortCircuit(leftVal, ctx)
+	}
+	rightVal, err := b.Right.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b.MatrixType != nil {
+		return b.codegenMatrix(leftVal, rightVal, ctx)
+	}
+	// Signedness of the operands, the result of a comparison is B1. Characters compare as code points
+	unsigned := isTypeUnsigned_Type(getExprType(b.Left)) || isTypeChar_Type(getExprType(b.Left))
+
+	switch b.Operator {
+	case SymbolPlus:
+		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
+			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
+			return ctx.Block.NewFAdd(leftVal, rightVal), nil
+		}
+		return ctx.Block.NewAdd(leftVal, rightVal), nil
+	case SymbolMinus:
+		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
+			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
+			return ctx.Block.NewFSub(leftVal, rightVal), nil
+		}
+		return ctx.Block.NewSub(leftVal, rightVal), nil
+	case SymbolAsterisk:
+		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) &&
+			(rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
+			return ctx.Block.NewFMul(leftVal, rightVal), nil
+		}
+		return ctx.Block.NewMul(leftVal, rightVal), nil
+	case SymbolSlash:
+		if canICmp(leftVal, rightVal) {
+			if unsigned {
+				return ctx.Block.NewUDiv(leftVal, rightVal), nil
+			}
+			return ctx.Block.NewSDiv(leftVal, rightVal), nil
+		}
+		if (leftVal.Type().Equal(types.Double) || leftVal.Type().Equal(types.Float)) && (rightVal.Type().Equal(types.Double) || rightVal.Type().Equal(types.Float)) {
+			return ctx.Block.NewFDiv(leftVal, rightVal), nil
+		}
+		return nil, fmt.Errorf("gặp sự cố khi thực hiện phép toán")
+	case SymbolLess:
+		if canICmp(leftVal, rightVal) {
+			return ctx.Block.NewICmp(intPred(enum.IPredSLT, enum.IPredULT, unsigned), leftVal, rightVal), nil
+		} else if canFCmp(leftVal, rightVal) {
+			return ctx.Block.NewFCmp(enum.FPredOLT, leftVal, rightVal), nil
 		}
 		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
 	case SymbolLessEqual:
 		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredSLE, leftVal, rightVal), nil
+			return ctx.Block.NewICmp(intPred(enum.IPredSLE, enum.IPredULE, unsigned), leftVal, rightVal), nil
 		} else if canFCmp(leftVal, rightVal) {
 			return ctx.Block.NewFCmp(enum.FPredOLE, leftVal, rightVal), nil
 		}
 		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
 	case SymbolGreater:
 		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredSGT, leftVal, rightVal), nil
+			return ctx.Block.NewICmp(intPred(enum.IPredSGT, enum.IPredUGT, unsigned), leftVal, rightVal), nil
 		} else if canFCmp(leftVal, rightVal) {
 			return ctx.Block.NewFCmp(enum.FPredOGT, leftVal, rightVal), nil
 		}
 		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
 	case SymbolGreaterEqual:
 		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredSGE, leftVal, rightVal), nil
+			return ctx.Block.NewICmp(intPred(enum.IPredSGE, enum.IPredUGE, unsigned), leftVal, rightVal), nil
 		} else if canFCmp(leftVal, rightVal) {
 			return ctx.Block.NewFCmp(enum.FPredOGE, leftVal, rightVal), nil
 		}
-		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
-	case SymbolEqual:
-		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredEQ, leftVal, rightVal), nil
-		} else if canFCmp(leftVal, rightVal) {
-			return ctx.Block.NewFCmp(enum.FPredOEQ, leftVal, rightVal), nil
+		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
+	case SymbolEqual:
+		if canICmp(leftVal, rightVal) {
+			return ctx.Block.NewICmp(enum.IPredEQ, leftVal, rightVal), nil
+		} else if canFCmp(leftVal, rightVal) {
+			return ctx.Block.NewFCmp(enum.FPredOEQ, leftVal, rightVal), nil
+		} else if canStrCmp(leftVal, rightVal) {
+			cmp := ctx.Block.NewCall(findFunction(ctx.Module, "strcmp"), leftVal, rightVal)
+			return ctx.Block.NewICmp(enum.IPredEQ, cmp, constant.NewInt(types.I32, 0)), nil
+		}
+		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
+	case SymbolNotEqual:
+		if canICmp(leftVal, rightVal) {
+			return ctx.Block.NewICmp(enum.IPredNE, leftVal, rightVal), nil
+		} else if canFCmp(leftVal, rightVal) {
+			return ctx.Block.NewFCmp(enum.FPredONE, leftVal, rightVal), nil
+		} else if canStrCmp(leftVal, rightVal) {
+			cmp := ctx.Block.NewCall(findFunction(ctx.Module, "strcmp"), leftVal, rightVal)
+			return ctx.Block.NewICmp(enum.IPredNE, cmp, constant.NewInt(types.I32, 0)), nil
+		}
+		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
+	case SymbolModulo:
+		if _, ok := leftVal.Type().(*types.FloatType); ok {
+			return ctx.Block.NewFRem(leftVal, rightVal), nil
+		}
+		if unsigned {
+			return ctx.Block.NewURem(leftVal, rightVal), nil
+		}
+		return ctx.Block.NewSRem(leftVal, rightVal), nil
+	case SymbolCaret:
+		if floatType, ok := leftVal.Type().(*types.FloatType); ok {
+			return ctx.Block.NewCall(ctx.floatPowFunc(floatType), leftVal, rightVal), nil
+		}
+		if intType, ok := leftVal.Type().(*types.IntType); ok {
+			return ctx.Block.NewCall(ctx.intPowFunc(intType, unsigned), leftVal, rightVal), nil
+		}
+		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
+	case SymbolAmpersand:
+		return ctx.Block.NewAnd(leftVal, rightVal), nil
+	case SymbolPipe:
+		return ctx.Block.NewOr(leftVal, rightVal), nil
+	case KeywordXor:
+		return ctx.Block.NewXor(leftVal, rightVal), nil
+	case SymbolShiftLeft:
+		return ctx.Block.NewShl(leftVal, rightVal), nil
+	case SymbolShiftRight:
+		// Unsigned numbers shift in zeroes, signed ones keep their sign
+		if unsigned {
+			return ctx.Block.NewLShr(leftVal, rightVal), nil
+		}
+		return ctx.Block.NewAShr(leftVal, rightVal), nil
+	default:
+		return nil, fmt.Errorf("gặp sự cố khi thực hiện phép toán")
+	}
+}
+
+// Calls printf with a constant format string
+func (ctx *CodegenContext) printf(format string, args ...value.Value) value.Value {
+	printf := findFunction(ctx.Module, "printf")
+	return ctx.Block.NewCall(printf, append([]value.Value{ctx.constString(format)}, args...)...)
+}
+
+// Prints a value the way in() shows it, without the trailing newline
+func (ctx *CodegenContext) printValue(val value.Value, typ Type) error {
+	if containerType, ok := typ.(*ContainerType); ok {
+		switch {
+		case containerType.Kind == ContainerMatrix && !containerType.IsDynamic:
+			return ctx.printMatrix(val, containerType)
+		case containerType.Kind == ContainerArray:
+			return ctx.printArray(val, containerType)
+		}
+		return fmt.Errorf("unsupported type for in(): %s", typ)
+	}
+	if isTypeChar_Type(typ) {
+		// Encoded as UTF-8 so Vietnamese letters show up as themselves
+		ctx.Block.NewCall(ctx.printCharFunc(), ctx.castInt(val, types.I32, true))
+		return nil
+	}
+
+	unsigned := isTypeUnsigned_Type(typ)
+	var fmtStr string
+	switch val.Type().String() {
+	case "i1":
+		// Printed as the literal that would produce it
+		val = ctx.Block.NewSelect(val, ctx.constString(KeywordDdung), ctx.constString(KeywordSai))
+		fmtStr = "%s"
+	case "i32":
+		fmtStr = "%d"
+		if unsigned {
+			fmtStr = "%u"
+		}
+	case "i64":
+		fmtStr = "%ld"
+		if unsigned {
+			fmtStr = "%lu"
+		}
+	case "float":
+		// Variadic arguments are passed as double
+		val = ctx.Block.NewFPExt(val, types.Double)
+		fmtStr = "%f"
+	case "double":
+		fmtStr = "%f"
+	case "i8*": // string pointer
+		fmtStr = "%s"
+	default:
+		return fmt.Errorf("unsupported type for in(): %s", val.Type().String())
+	}
+	ctx.printf(fmtStr, val)
+	return nil
+}
+
+// Prints a matrix one row per line, for example "[1, 2]" then "[3, 4]"
+func (ctx *CodegenContext) printMatrix(val value.Value, typ *ContainerType) error {
+	matrix := ctx.viewMatrix(val)
+	zero := constant.NewInt(types.I64, 0)
+	lastRow := ctx.Block.NewSub(matrix.rows, constant.NewInt(types.I64, 1))
+	return ctx.emitLoop(matrix.rows, func(r value.Value) error {
+		ctx.printf("[")
+		err := ctx.emitLoop(matrix.cols, func(c value.Value) error {
+			first := ctx.Block.NewICmp(enum.IPredEQ, c, zero)
+			ctx.printf("%s", ctx.Block.NewSelect(first, ctx.constString(""), ctx.constString(", ")))
+			return ctx.printValue(ctx.Block.NewLoad(matrix.at(r, c, ctx)), typ.ElementType)
+		})
+		if err != nil {
+			return err
+		}
+		last := ctx.Block.NewICmp(enum.IPredEQ, r, lastRow)
+		ctx.printf("]%s", ctx.Block.NewSelect(last, ctx.constString(""), ctx.constString("\n")))
+		return nil
+	})
+}
+
+// Prints an array on one line, for example "[1, 2, 3]"
+func (ctx *CodegenContext) printArray(val value.Value, typ *ContainerType) error {
+	var data, length value.Value
+	switch {
+	case typ.IsDynamic:
+		data, length = ctx.Block.NewExtractValue(val, 0), ctx.Block.NewExtractValue(val, 1)
+	case typ.IsRuntimeSized:
+		data, length = ctx.Block.NewExtractValue(val, 0), ctx.runtimeLength(val, 0)
+	default:
+		arrType := val.Type().(*types.ArrayType)
+		ptr := ctx.Func.Blocks[0].NewAlloca(arrType)
+		ctx.Block.NewStore(val, ptr)
+		data, length = ctx.Block.NewBitCast(ptr, types.NewPointer(arrType.ElemType)), constant.NewInt(types.I64, int64(arrType.Len))
+	}
+	zero := constant.NewInt(types.I64, 0)
+	ctx.printf("[")
+	err := ctx.emitLoop(length, func(k value.Value) error {
+		first := ctx.Block.NewICmp(enum.IPredEQ, k, zero)
+		ctx.printf("%s", ctx.Block.NewSelect(first, ctx.constString(""), ctx.constString(", ")))
+		return ctx.printValue(ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, k)), typ.ElementType)
+	})
+	if err != nil {
+		return err
+	}
+	ctx.printf("]")
+	return nil
+}
+
+// A matrix laid out row by row in memory, with its shape as i64 values
+type matrixView struct {
+	data value.Value // Pointer to the first element
+	rows value.Value
+	cols value.Value
+}
+
+// Fixed size matrices are spilled to memory so elements can be picked at runtime.
+// Runtime sized ones already live on the heap and are viewed in place
+func (ctx *CodegenContext) viewMatrix(val value.Value) matrixView {
+	if _, ok := val.Type().(*types.StructType); ok {
+		return matrixView{
+			data: ctx.Block.NewExtractValue(val, 0),
+			rows: ctx.runtimeLength(val, 0),
+			cols: ctx.runtimeLength(val, 1),
+		}
+	}
+	ptr := ctx.Func.Blocks[0].NewAlloca(val.Type())
+	ctx.Block.NewStore(val, ptr)
+	return ctx.newMatrixView(ptr)
+}
+
+// View of a fixed size matrix already in memory
+func (ctx *CodegenContext) newMatrixView(ptr value.Value) matrixView {
+	matrixType := ptr.Type().(*types.PointerType).ElemType.(*types.ArrayType)
+	rowType := matrixType.ElemType.(*types.ArrayType)
+	return matrixView{
+		data: ctx.Block.NewBitCast(ptr, types.NewPointer(rowType.ElemType)),
+		rows: constant.NewInt(types.I64, int64(matrixType.Len)),
+		cols: constant.NewInt(types.I64, int64(rowType.Len)),
+	}
+}
+
+func (m matrixView) at(r, c value.Value, ctx *CodegenContext) value.Value {
+	return ctx.Block.NewGetElementPtr(m.data, ctx.Block.NewAdd(ctx.Block.NewMul(r, m.cols), c))
+}
+
+// Storage for a matrix result with the given shape. done gives the matrix value once it's filled in
+func (ctx *CodegenContext) newMatrix(typ *ContainerType, rows, cols value.Value) (result matrixView, done func() value.Value, err error) {
+	if typ.IsRuntimeSized {
+		arr, err := ctx.newRuntimeArray(typ, []value.Value{rows, cols})
+		if err != nil {
+			return matrixView{}, nil, err
+		}
+		result = matrixView{data: ctx.Block.NewExtractValue(arr, 0), rows: rows, cols: cols}
+		return result, func() value.Value { return arr }, nil
+	}
+	llvmType, err := llvmTypeFromType(typ, ctx)
+	if err != nil {
+		return matrixView{}, nil, err
+	}
+	ptr := ctx.Func.Blocks[0].NewAlloca(llvmType)
+	return ctx.newMatrixView(ptr), func() value.Value { return ctx.Block.NewLoad(ptr) }, nil
+}
+
+// Stops the program when two sizes differ. Sizes known while compiling are compared right away
+func (ctx *CodegenContext) checkSize(a, b value.Value) error {
+	constA, ok1 := a.(*constant.Int)
+	constB, ok2 := b.(*constant.Int)
+	if ok1 && ok2 {
+		if constA.X.Cmp(constB.X) != 0 {
+			return fmt.Errorf("kích thước của ma trận không khớp: %v và %v", constA.X, constB.X)
+		}
+		return nil
+	}
+	return ctx.runtimeCheck(ctx.Block.NewICmp(enum.IPredEQ, a, b), ".errstr_matrix_shape")
+}
+
+// Element-wise sum and difference, scaling and the matrix product
+func (b *BinaryExpr) codegenMatrix(leftVal, rightVal value.Value, ctx *CodegenContext) (value.Value, error) {
+	// Scaling: one side is a number
+	if !isMatrixType(getExprType(b.Left)) || !isMatrixType(getExprType(b.Right)) {
+		matrix, scalar := leftVal, rightVal
+		if !isMatrixType(getExprType(b.Left)) {
+			matrix, scalar = rightVal, leftVal
+		}
+		m := ctx.viewMatrix(matrix)
+		result, done, err := ctx.newMatrix(b.MatrixType, m.rows, m.cols)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.emitLoop(ctx.Block.NewMul(m.rows, m.cols), func(i value.Value) error {
+			elem := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(m.data, i))
+			ctx.Block.NewStore(ctx.arith(SymbolAsterisk, elem, scalar), ctx.Block.NewGetElementPtr(result.data, i))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return done(), nil
+	}
+
+	left, right := ctx.viewMatrix(leftVal), ctx.viewMatrix(rightVal)
+	if b.Operator == SymbolAsterisk {
+		err := ctx.checkSize(left.cols, right.rows)
+		if err != nil {
+			return nil, err
+		}
+		result, done, err := ctx.newMatrix(b.MatrixType, left.rows, right.cols)
+		if err != nil {
+			return nil, err
+		}
+		elemType := result.data.Type().(*types.PointerType).ElemType
+		sum := ctx.Func.Blocks[0].NewAlloca(elemType)
+		err = ctx.emitLoop(result.rows, func(r value.Value) error {
+			return ctx.emitLoop(result.cols, func(c value.Value) error {
+				ctx.Block.NewStore(constant.NewZeroInitializer(elemType), sum)
+				err := ctx.emitLoop(left.cols, func(k value.Value) error {
+					product := ctx.arith(SymbolAsterisk, ctx.Block.NewLoad(left.at(r, k, ctx)), ctx.Block.NewLoad(right.at(k, c, ctx)))
+					ctx.Block.NewStore(ctx.arith(SymbolPlus, ctx.Block.NewLoad(sum), product), sum)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				ctx.Block.NewStore(ctx.Block.NewLoad(sum), result.at(r, c, ctx))
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		return done(), nil
+	}
+
+	err := ctx.checkSize(left.rows, right.rows)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.checkSize(left.cols, right.cols)
+	if err != nil {
+		return nil, err
+	}
+	result, done, err := ctx.newMatrix(b.MatrixType, left.rows, left.cols)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.emitLoop(ctx.Block.NewMul(left.rows, left.cols), func(i value.Value) error {
+		l := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(left.data, i))
+		r := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(right.data, i))
+		ctx.Block.NewStore(ctx.arith(b.Operator, l, r), ctx.Block.NewGetElementPtr(result.data, i))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done(), nil
+}
+
+// +, - or * on two numbers of the same type
+func (ctx *CodegenContext) arith(op string, a, b value.Value) value.Value {
+	_, isFloat := a.Type().(*types.FloatType)
+	switch {
+	case op == SymbolPlus && isFloat:
+		return ctx.Block.NewFAdd(a, b)
+	case op == SymbolPlus:
+		return ctx.Block.NewAdd(a, b)
+	case op == SymbolMinus && isFloat:
+		return ctx.Block.NewFSub(a, b)
+	case op == SymbolMinus:
+		return ctx.Block.NewSub(a, b)
+	case isFloat:
+		return ctx.Block.NewFMul(a, b)
+	default:
+		return ctx.Block.NewMul(a, b)
+	}
+}
+
+// chuyển_vị(m), đơn_vị(n) and định_thức(m)
+func (c *CallExpr) codegenMatrixBuiltin(ctx *CodegenContext) (value.Value, error) {
+	if c.Name == "đơn_vị" {
+		// The size is a literal, so the whole matrix is a constant
+		n := c.ReturnType.(*ContainerType)
+		shape, _ := literalShape(n)
+		rows := make([]constant.Constant, shape[0])
+		for r := range rows {
+			row := make([]constant.Constant, shape[0])
+			for k := range row {
+				row[k] = constant.NewFloat(types.Double, 0)
+			}
+			row[r] = constant.NewFloat(types.Double, 1)
+			rows[r] = constant.NewArray(row...)
+		}
+		return constant.NewArray(rows...), nil
+	}
+
+	val, err := c.Arguments[0].Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matrix := ctx.viewMatrix(val)
+	if c.Name == "định_thức" {
+		err := ctx.checkSize(matrix.rows, matrix.cols)
+		if err != nil {
+			return nil, err
+		}
+		// The determinant is worked out in place, fixed size matrices were already copied by the view
+		if _, ok := val.Type().(*types.StructType); !ok {
+			return ctx.Block.NewCall(ctx.determinantFunc(), matrix.data, matrix.rows), nil
+		}
+		size := ctx.Block.NewMul(ctx.Block.NewMul(matrix.rows, matrix.cols), sizeOf(types.Double))
+		scratch := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), size)
+		ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), scratch, ctx.Block.NewBitCast(matrix.data, types.I8Ptr), size)
+		det := ctx.Block.NewCall(ctx.determinantFunc(), ctx.Block.NewBitCast(scratch, matrix.data.Type()), matrix.rows)
+		ctx.Block.NewCall(findFunction(ctx.Module, "free"), scratch)
+		return det, nil
+	}
+
+	result, done, err := ctx.newMatrix(c.ReturnType.(*ContainerType), matrix.cols, matrix.rows)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.emitLoop(matrix.rows, func(r value.Value) error {
+		return ctx.emitLoop(matrix.cols, func(k value.Value) error {
+			ctx.Block.NewStore(ctx.Block.NewLoad(matrix.at(r, k, ctx)), result.at(k, r, ctx))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done(), nil
+}
+
+// Emits a loop running body with i going from 0 to n-1, i is an i64.
+// The first error from body is returned once the loop is emitted
+func (ctx *CodegenContext) emitLoop(n value.Value, body func(i value.Value) error) error {
+	var err error
+	ctx.emitRange(n, func(i value.Value) {
+		if err == nil {
+			err = body(i)
+		}
+	})
+	return err
+}
+
+// Like emitLoop, for bodies that only emit instructions
+func (ctx *CodegenContext) emitRange(n value.Value, body func(i value.Value)) {
+	counter := ctx.Func.Blocks[0].NewAlloca(types.I64)
+	ctx.Block.NewStore(constant.NewInt(types.I64, 0), counter)
+
+	loopID := ctx.NextLoopID()
+	condBlock := ctx.Func.NewBlock(fmt.Sprintf("rep.cond.%d", loopID))
+	bodyBlock := ctx.Func.NewBlock(fmt.Sprintf("rep.body.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("rep.end.%d", loopID))
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = condBlock
+	i := ctx.Block.NewLoad(counter)
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredSLT, i, n), bodyBlock, leaveBlock)
+
+	ctx.Block = bodyBlock
+	body(i)
+	ctx.Block.NewStore(ctx.Block.NewAdd(i, constant.NewInt(types.I64, 1)), counter)
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = leaveBlock
+}
+
+// thêm(a, x) appends x to the dynamic array a, growing its storage when full
+func (c *CallExpr) codegenAppend(ctx *CodegenContext) (value.Value, error) {
+	arrPtr, err := addressOf(c.Arguments[0], ctx)
+	if err != nil {
+		return nil, err
+	}
+	val, err := ctx.valueOf(c.Arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	arrType := arrPtr.Type().(*types.PointerType).ElemType.(*types.StructType)
+	elemType := arrType.Fields[0].(*types.PointerType).ElemType
+
+	generic := ctx.Block.NewBitCast(arrPtr, types.NewPointer(dynamicArrayType(types.I8)))
+	ctx.Block.NewCall(ctx.arrayReserveFunc(), generic, sizeOf(elemType))
+
+	zero := constant.NewInt(types.I32, 0)
+	data := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(arrPtr, zero, zero))
+	lenPtr := ctx.Block.NewGetElementPtr(arrPtr, zero, constant.NewInt(types.I32, 1))
+	length := ctx.Block.NewLoad(lenPtr)
+	ctx.Block.NewStore(val, ctx.Block.NewGetElementPtr(data, length))
+	ctx.Block.NewStore(ctx.Block.NewAdd(length, constant.NewInt(types.I64, 1)), lenPtr)
+	return nil, nil
+}
+
+// độ_dài(a) is the current length of a dynamic array, or the fixed length of a static one.
+// For a map it's the number of entries
+func (c *CallExpr) codegenLength(ctx *CodegenContext) (value.Value, error) {
+	arrType, ok := getExprType(c.Arguments[0]).(*ContainerType)
+	if !ok {
+		line, col := c.Arguments[0].Pos()
+		return nil, NewLangError(InvalidBuiltinArgument, c.Name, ContainerArray, getExprType(c.Arguments[0])).At(line, col)
+	}
+	if arrType.IsDynamic || arrType.IsRuntimeSized || arrType.Kind == ContainerHashMap {
+		val, err := c.Arguments[0].Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case arrType.Kind == ContainerHashMap:
+			return ctx.Block.NewExtractValue(val, mapLength), nil
+		case arrType.IsRuntimeSized:
+			return ctx.runtimeLength(val, 0), nil
+		}
+		return ctx.Block.NewExtractValue(val, 1), nil
+	}
+	llvmType, err := llvmTypeFromType(arrType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return constant.NewInt(types.I64, int64(llvmType.(*types.ArrayType).Len)), nil
+}
+
+// sàn(a) and trần(a), growable arrays go from 0 to their length - 1
+func (c *CallExpr) codegenBound(ctx *CodegenContext) (value.Value, error) {
+	arrType := getExprType(c.Arguments[0]).(*ContainerType)
+	if !arrType.IsDynamic && !arrType.IsRuntimeSized {
+		bounds, err := constBounds(arrType, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if c.Name == "sàn" {
+			return constant.NewInt(types.I64, bounds[0][0]), nil
+		}
+		return constant.NewInt(types.I64, bounds[0][1]), nil
+	}
+	arr, err := ctx.openArray(c.Arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	if c.Name == "sàn" {
+		return ctx.Block.NewExtractValue(arr, 1), nil
+	}
+	return ctx.Block.NewExtractValue(arr, 2), nil
+}
+
+// sắp_xếp, tìm_nhị_phân, nhỏ_nhất, lớn_nhất and đảo_ngược work on an open view of the array,
+// so sorting and reversing change the caller's elements
+func (c *CallExpr) codegenOrderBuiltin(ctx *CodegenContext) (value.Value, error) {
+	elemType := getExprType(c.Arguments[0]).(*ContainerType).ElementType
+	arr, err := ctx.openArray(c.Arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	data := ctx.Block.NewExtractValue(arr, 0)
+	lower := ctx.Block.NewExtractValue(arr, 1)
+	length := ctx.Block.NewAdd(ctx.Block.NewSub(ctx.Block.NewExtractValue(arr, 2), lower), constant.NewInt(types.I64, 1))
+
+	switch c.Name {
+	case "sắp_xếp":
+		descending, err := c.Arguments[1].Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.Block.NewCall(ctx.sortFunc(elemType, data.Type()), data, length, descending), nil
+	case "tìm_nhị_phân":
+		x, err := c.Arguments[1].Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// A missing x gives -1, which becomes sàn(a) - 1
+		offset := ctx.Block.NewCall(ctx.searchFunc(elemType, data.Type()), data, length, x)
+		return ctx.Block.NewAdd(lower, offset), nil
+	case "nhỏ_nhất", "lớn_nhất":
+		err = ctx.runtimeCheck(ctx.Block.NewICmp(enum.IPredSGT, length, constant.NewInt(types.I64, 0)), ".errstr_array_empty")
+		if err != nil {
+			return nil, err
+		}
+		largest := constant.NewBool(c.Name == "lớn_nhất")
+		return ctx.Block.NewCall(ctx.extremeFunc(elemType, data.Type()), data, length, largest), nil
+	}
+	size := sizeOf(data.Type().(*types.PointerType).ElemType)
+	raw := ctx.Block.NewBitCast(data, types.I8Ptr)
+	return ctx.Block.NewCall(ctx.reverseFunc(), raw, length, size), nil
+}
+
+// tìm(m, k, x), xoá(m, k) and các_khoá(m)
+func (c *CallExpr) codegenMapBuiltin(ctx *CodegenContext) (value.Value, error) {
+	mapType := getExprType(c.Arguments[0]).(*ContainerType)
+	var mapPtr value.Value
+	var err error
+	switch c.Arguments[0].(type) {
+	case *Identifier, *IndexExpr, *FieldExpr:
+		mapPtr, err = addressOf(c.Arguments[0], ctx)
+	default:
+		var val value.Value
+		val, err = c.Arguments[0].Codegen(ctx)
+		if err == nil {
+			mapPtr = ctx.Func.Blocks[0].NewAlloca(val.Type())
+			ctx.Block.NewStore(val, mapPtr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Name == "các_khoá" {
+		return ctx.mapKeys(mapPtr, mapType)
+	}
+
+	keyVal, err := c.Arguments[1].Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, str := ctx.mapKey(keyVal, mapType.KeyType)
+	if c.Name == "xoá" {
+		return ctx.Block.NewCall(ctx.mapRemoveFunc(), mapPtr, key, str), nil
+	}
+
+	// The value is only written when the key is there
+	index := ctx.Block.NewCall(ctx.mapFindFunc(), mapPtr, key, str)
+	found := ctx.Block.NewICmp(enum.IPredNE, index, constant.NewInt(types.I64, -1))
+	flowID := ctx.NextFlowID()
+	copyBlock := ctx.Func.NewBlock(fmt.Sprintf("found.%d", flowID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("found.end.%d", flowID))
+	ctx.Block.NewCondBr(found, copyBlock, leaveBlock)
+
+	ctx.Block = copyBlock
+	target, err := addressOf(c.Arguments[2], ctx)
+	if err != nil {
+		return nil, err
+	}
+	valueType := target.Type().(*types.PointerType).ElemType
+	ctx.Block.NewStore(ctx.Block.NewLoad(ctx.mapValueAt(mapPtr, index, valueType)), target)
+	ctx.Block.NewBr(leaveBlock)
+
+	ctx.Block = leaveBlock
+	return found, nil
+}
+
+// Copies the keys of a map into a new dynamic array
+func (ctx *CodegenContext) mapKeys(mapPtr value.Value, mapType *ContainerType) (value.Value, error) {
+	keyType, err := llvmTypeFromType(mapType.KeyType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	length := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapLength))
+	raw := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), ctx.Block.NewMul(length, sizeOf(keyType)))
+	data := ctx.Block.NewBitCast(raw, types.NewPointer(keyType))
+	count := ctx.Func.Blocks[0].NewAlloca(types.I64)
+	ctx.Block.NewStore(constant.NewInt(types.I64, 0), count)
+
+	capacity := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapCapacity))
+	err = ctx.emitLoop(capacity, func(j value.Value) error {
+		flowID := ctx.NextFlowID()
+		addBlock := ctx.Func.NewBlock(fmt.Sprintf("keys.add.%d", flowID))
+		nextBlock := ctx.Func.NewBlock(fmt.Sprintf("keys.next.%d", flowID))
+		states := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapStates))
+		state := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(states, j))
+		ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, state, constant.NewInt(types.I8, slotFull)), addBlock, nextBlock)
+
+		ctx.Block = addBlock
+		keys := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapKeys))
+		var key value.Value = ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(keys, j))
+		if _, ok := keyType.(*types.PointerType); ok {
+			key = ctx.Block.NewIntToPtr(key, keyType)
+		} else {
+			key = ctx.castInt(key, keyType, true)
+		}
+		n := ctx.Block.NewLoad(count)
+		ctx.Block.NewStore(key, ctx.Block.NewGetElementPtr(data, n))
+		ctx.Block.NewStore(ctx.Block.NewAdd(n, constant.NewInt(types.I64, 1)), count)
+		ctx.Block.NewBr(nextBlock)
+
+		ctx.Block = nextBlock
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The array owns its storage, so its capacity is its length
+	var arr value.Value = constant.NewUndef(dynamicArrayType(keyType))
+	arr = ctx.Block.NewInsertValue(arr, data, 0)
+	arr = ctx.Block.NewInsertValue(arr, length, 1)
+	arr = ctx.Block.NewInsertValue(arr, length, 2)
+	return arr, nil
+}
+
+// Compares the shapes, then every element until one differs
+func (b *BinaryExpr) codegenContainerEquality(ctx *CodegenContext) (value.Value, error) {
+	var data [2]value.Value
+	var shapes [2][]value.Value
+	for side, expr := range []Expression{b.Left, b.Right} {
+		if isMatrixType(getExprType(expr)) {
+			val, err := expr.Codegen(ctx)
+			if err != nil {
+				return nil, err
+			}
+			matrix := ctx.viewMatrix(val)
+			data[side], shapes[side] = matrix.data, []value.Value{matrix.rows, matrix.cols}
+			continue
+		}
+		arr, err := ctx.openArray(expr)
+		if err != nil {
+			return nil, err
+		}
+		data[side], shapes[side] = ctx.Block.NewExtractValue(arr, 0), []value.Value{ctx.runtimeLength(arr, 0)}
+	}
+
+	var sameShape value.Value = constant.True
+	count := value.Value(constant.NewInt(types.I64, 1))
+	for d := range shapes[0] {
+		sameShape = ctx.Block.NewAnd(sameShape, ctx.Block.NewICmp(enum.IPredEQ, shapes[0][d], shapes[1][d]))
+		count = ctx.Block.NewMul(count, shapes[0][d])
+	}
+	same := ctx.Func.Blocks[0].NewAlloca(types.I1)
+	ctx.Block.NewStore(sameShape, same)
+	// Nothing is compared when the shapes differ
+	count = ctx.Block.NewSelect(sameShape, count, constant.NewInt(types.I64, 0))
+	err := ctx.emitLoop(count, func(k value.Value) error {
+		left := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data[0], k))
+		right := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data[1], k))
+		ctx.Block.NewStore(ctx.Block.NewAnd(ctx.Block.NewLoad(same), ctx.equalValues(left, right)), same)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := ctx.Block.NewLoad(same)
+	if b.Operator == SymbolNotEqual {
+		return ctx.Block.NewXor(result, constant.True), nil
+	}
+	return result, nil
+}
+
+// Whether two values of the same primitive type are equal, strings compare their contents
+func (ctx *CodegenContext) equalValues(left, right value.Value) value.Value {
+	switch {
+	case canFCmp(left, right):
+		return ctx.Block.NewFCmp(enum.FPredOEQ, left, right)
+	case canStrCmp(left, right):
+		cmp := ctx.Block.NewCall(findFunction(ctx.Module, "strcmp"), left, right)
+		return ctx.Block.NewICmp(enum.IPredEQ, cmp, constant.NewInt(types.I32, 0))
+	}
+	return ctx.Block.NewICmp(enum.IPredEQ, left, right)
+}
+
+// Picks the unsigned predicate for N32/N64 operands
+func intPred(signed, unsignedPred enum.IPred, unsigned bool) enum.IPred {
+	if unsigned {
+		return unsignedPred
+	}
+	return signed
+}
+
+// "và"/"hoặc" only evaluate the right side when the left side doesn't decide the result,
+// so guards like "i <= 10 và a[i] > 0" never touch a[i] out of bounds
+func (b *BinaryExpr) codegenShortCircuit(leftVal value.Value, ctx *CodegenContext) (value.Value, error) {
+	flowID := ctx.NextFlowID()
+	prefix := "and"
+	if b.Operator == KeywordHoac {
+		prefix = "or"
+	}
+	rightBlock := ctx.Func.NewBlock(fmt.Sprintf("%s.rhs.%d", prefix, flowID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("%s.end.%d", prefix, flowID))
+
+	// When skipping the right side, the result is the left side's value
+	leftBlock := ctx.Block
+	var skipped *constant.Int
+	if b.Operator == KeywordVa {
+		skipped = constant.False
+		ctx.Block.NewCondBr(leftVal, rightBlock, leaveBlock)
+	} else {
+		skipped = constant.True
+		ctx.Block.NewCondBr(leftVal, leaveBlock, rightBlock)
+	}
+
+	ctx.Block = rightBlock
+	rightVal, err := b.Right.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rightEnd := ctx.Block // The right side may have added blocks of its own
+	ctx.Block.NewBr(leaveBlock)
+
+	ctx.Block = leaveBlock
+	return ctx.Block.NewPhi(ir.NewIncoming(skipped, leftBlock), ir.NewIncoming(rightVal, rightEnd)), nil
+}
+
+func (u *UnaryExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	val, err := u.Operand.Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Operator {
+	case SymbolMinus:
+		if floatType, ok := val.Type().(*types.FloatType); ok {
+			// This version of llir has no fneg, "fsub -0.0, x" is the equivalent
+			return ctx.Block.NewFSub(constant.NewFloat(floatType, math.Copysign(0, -1)), val), nil
+		}
+		if intType, ok := val.Type().(*types.IntType); ok {
+			return ctx.Block.NewSub(constant.NewInt(intType, 0), val), nil
 		}
-		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
-	case SymbolNotEqual:
-		if canICmp(leftVal, rightVal) {
-			return ctx.Block.NewICmp(enum.IPredNE, leftVal, rightVal), nil
-		} else if canFCmp(leftVal, rightVal) {
-			return ctx.Block.NewFCmp(enum.FPredONE, leftVal, rightVal), nil
+	case SymbolBang:
+		if val.Type().Equal(types.I1) {
+			return ctx.Block.NewXor(val, constant.True), nil
 		}
-		return nil, NewLangError(ErrorBinaryExpr, leftVal.Type(), rightVal.Type()).At(b.Line, b.Column)
-	case KeywordVa:
-		return ctx.Block.NewAnd(leftVal, rightVal), nil
-	case KeywordHoac:
-		return ctx.Block.NewOr(leftVal, rightVal), nil
-	default:
-		return nil, fmt.Errorf("gặp sự cố khi thực hiện phép toán")
 	}
+	return nil, NewLangError(InvalidOperand, u.Operator, val.Type()).At(u.Line, u.Column)
 }
 
 func (c *CallExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	switch c.Name {
+	case "thêm":
+		return c.codegenAppend(ctx)
+	case "độ_dài":
+		return c.codegenLength(ctx)
+	case "sàn", "trần":
+		return c.codegenBound(ctx)
+	case "chuyển_vị", "đơn_vị", "định_thức":
+		return c.codegenMatrixBuiltin(ctx)
+	case "tìm", "xoá", "các_khoá":
+		return c.codegenMapBuiltin(ctx)
+	case "sắp_xếp", "tìm_nhị_phân", "nhỏ_nhất", "lớn_nhất", "đảo_ngược":
+		return c.codegenOrderBuiltin(ctx)
+	}
 	if c.Name == "in" {
 		if len(c.Arguments) != 1 {
 			return nil, fmt.Errorf("in() cần ít nhất một đối số")
@@ -398,25 +2161,11 @@ func (c *CallExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
 		if err != nil {
 			return nil, err
 		}
-		printf := findFunction(ctx.Module, "printf")
-
-		var fmtStr string
-		switch argVal.Type().String() {
-		case "i32", "i64":
-			fmtStr = "%d"
-		case "float", "double":
-			fmtStr = "%f"
-		case "i8*": // string pointer
-			fmtStr = "%s"
-		default:
-			return nil, fmt.Errorf("unsupported type for in(): %s", argVal.Type().String())
+		err = ctx.printValue(argVal, getExprType(c.Arguments[0]))
+		if err != nil {
+			return nil, err
 		}
-		globalStr := ctx.GetOrCreateGlobalString(fmt.Sprintf("fmtstr_print_%s", fmtStr), fmtStr+"\n\x00")
-
-		zero := constant.NewInt(types.I64, 0)
-		fmtPtr := ctx.Block.NewGetElementPtr(globalStr, zero, zero) // I think?
-
-		return ctx.Block.NewCall(printf, fmtPtr, argVal), nil
+		return ctx.printf("\n"), nil
 	}
 
 	// Normal function calls
@@ -432,14 +2181,129 @@ func (c *CallExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
 	}
 
 	llvmArgs := make([]value.Value, len(c.Arguments))
+	var writeBacks []func()
 	for i, arg := range c.Arguments {
-		argVal, err := arg.Codegen(ctx)
+		var argVal value.Value
+		var err error
+		if fn, ok := ctx.Functions[c.Name]; ok && isOpenArray(fn.Parameters[i].Type) {
+			argVal, err = ctx.openArray(arg)
+			if global := ctx.globalGrowable(arg); err == nil && global != nil {
+				argVal = ctx.cloneRuntime(argVal)
+				writeBacks = append(writeBacks, ctx.writeBack(argVal, global))
+			}
+		} else if ok && isHashMap(fn.Parameters[i].Type) {
+			argVal, err = ctx.storageOf(arg)
+		} else {
+			// Other arguments are passed by value
+			argVal, err = ctx.valueOf(arg)
+		}
 		if err != nil {
 			return nil, err
 		}
 		llvmArgs[i] = argVal
 	}
-	return ctx.Block.NewCall(callee, llvmArgs...), nil
+	result := ctx.Block.NewCall(callee, llvmArgs...)
+	for _, writeBack := range writeBacks {
+		writeBack()
+	}
+	return result, nil
+}
+
+// The global growable array that arg is, or is a slice of. The function being called
+// can grow it, which moves its elements and would leave an open parameter looking at
+// freed memory, so such arguments are passed as a copy and written back afterwards
+func (ctx *CodegenContext) globalGrowable(arg Expression) value.Value {
+	for {
+		switch e := arg.(type) {
+		case *IndexExpr:
+			if e.SliceEnd == nil {
+				return nil
+			}
+			arg = e.Collection
+		case *Identifier:
+			containerType, ok := getExprType(e).(*ContainerType)
+			if _, local := ctx.Symbols[e.Name]; local || !ok || !containerType.IsDynamic {
+				return nil
+			}
+			if global, ok := ctx.Globals[e.Name]; ok {
+				return global
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+}
+
+// Returns a function that copies the elements of view back into the global growable
+// array it was taken from, where they start at index lower, and frees the copy
+func (ctx *CodegenContext) writeBack(view value.Value, global value.Value) func() {
+	return func() {
+		zero := constant.NewInt(types.I64, 0)
+		arr := ctx.Block.NewLoad(global)
+		lower := ctx.Block.NewExtractValue(view, 1)
+		// The function may also have replaced the array with a shorter one
+		count := ctx.runtimeLength(view, 0)
+		room := ctx.Block.NewSub(ctx.Block.NewExtractValue(arr, 1), lower)
+		count = ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredSLT, room, count), room, count)
+		count = ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredSLT, count, zero), zero, count)
+
+		data := ctx.Block.NewExtractValue(view, 0)
+		dst := ctx.Block.NewGetElementPtr(ctx.Block.NewExtractValue(arr, 0), lower)
+		size := ctx.Block.NewMul(count, sizeOf(data.Type().(*types.PointerType).ElemType))
+		raw := ctx.Block.NewBitCast(data, types.I8Ptr)
+		ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), ctx.Block.NewBitCast(dst, types.I8Ptr), raw, size)
+		ctx.Block.NewCall(findFunction(ctx.Module, "free"), raw)
+	}
+}
+
+func isOpenArray(typ Type) bool {
+	container, ok := typ.(*ContainerType)
+	return ok && container.IsOpen
+}
+
+func isHashMap(typ Type) bool {
+	container, ok := typ.(*ContainerType)
+	return ok && container.Kind == ContainerHashMap
+}
+
+// Open parameters get a { data, lower, upper } view of the caller's elements,
+// so changes made through them are seen by the caller
+func (ctx *CodegenContext) openArray(arg Expression) (value.Value, error) {
+	argType := getExprType(arg).(*ContainerType)
+	if argType.IsRuntimeSized {
+		return arg.Codegen(ctx)
+	}
+	elemType, err := llvmTypeFromType(argType.ElementType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var view value.Value = constant.NewUndef(runtimeArrayType(elemType, 1))
+
+	// Growable arrays start at 0
+	if argType.IsDynamic {
+		arr, err := arg.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		upper := ctx.Block.NewSub(ctx.Block.NewExtractValue(arr, 1), constant.NewInt(types.I64, 1))
+		view = ctx.Block.NewInsertValue(view, ctx.Block.NewExtractValue(arr, 0), 0)
+		view = ctx.Block.NewInsertValue(view, constant.NewInt(types.I64, 0), 1)
+		return ctx.Block.NewInsertValue(view, upper, 2), nil
+	}
+
+	// Fixed size arrays are passed by address, temporaries are spilled first
+	ptr, err := ctx.storageOf(arg)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := constBounds(argType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	view = ctx.Block.NewInsertValue(view, ctx.Block.NewBitCast(ptr, types.NewPointer(elemType)), 0)
+	view = ctx.Block.NewInsertValue(view, constant.NewInt(types.I64, bounds[0][0]), 1)
+	return ctx.Block.NewInsertValue(view, constant.NewInt(types.I64, bounds[0][1]), 2), nil
 }
 
 func (e *ExplicitCast) Codegen(ctx *CodegenContext) (value.Value, error) {
@@ -452,43 +2316,43 @@ func (e *ExplicitCast) Codegen(ctx *CodegenContext) (value.Value, error) {
 	if err != nil {
 		return nil, err
 	}
+	srcUnsigned := isTypeUnsigned_Type(getExprType(e.Argument)) || isTypeChar_Type(getExprType(e.Argument))
+	dstUnsigned := isTypeUnsigned_Type(&e.Type)
 
-	// Integer to Integer
+	// Integer to B1 is a test against zero, B1 to integer gives 1 or 0
 	if srcInt, ok1 := val.Type().(*types.IntType); ok1 {
-		if dstInt, ok2 := targetType.(*types.IntType); ok2 {
-			if srcInt.BitSize < dstInt.BitSize {
-				// Extend
-				if !isSameTypeAndName(&e.Type, &PrimitiveType{Name: PrimitiveZ32}) ||
-					!isSameTypeAndName(&e.Type, &PrimitiveType{Name: PrimitiveZ64}) {
-					return ctx.Block.NewZExt(val, targetType), nil
-				} else {
-					return ctx.Block.NewSExt(val, targetType), nil
-				}
-			} else if srcInt.BitSize > dstInt.BitSize {
-				// Truncate
-				return ctx.Block.NewTrunc(val, targetType), nil
-			} else {
-				// Same size
-				return val, nil
-			}
+		if targetType.Equal(types.I1) && !srcInt.Equal(types.I1) {
+			return ctx.Block.NewICmp(enum.IPredNE, val, constant.NewInt(srcInt, 0)), nil
+		}
+		if srcInt.Equal(types.I1) {
+			return ctx.castInt(val, targetType, true), nil
+		}
+	}
+
+	// Integer to Integer
+	if _, ok1 := val.Type().(*types.IntType); ok1 {
+		if _, ok2 := targetType.(*types.IntType); ok2 {
+			// Extension follows the source: N values are zero extended, Z values sign extended
+			return ctx.castInt(val, targetType, srcUnsigned), nil
 		}
 	}
 
 	// Integer to Float
 	if _, ok1 := val.Type().(*types.IntType); ok1 {
 		if _, ok2 := targetType.(*types.FloatType); ok2 {
-			if !isSameTypeAndName(&e.Type, &PrimitiveType{Name: PrimitiveR32}) ||
-				!isSameTypeAndName(&e.Type, &PrimitiveType{Name: PrimitiveR64}) {
-				// Signed int to float
-				return ctx.Block.NewSIToFP(val, targetType), nil
+			if srcUnsigned {
+				return ctx.Block.NewUIToFP(val, targetType), nil
 			}
+			return ctx.Block.NewSIToFP(val, targetType), nil
 		}
 	}
 
 	// Float to Integer
 	if _, ok1 := val.Type().(*types.FloatType); ok1 {
 		if _, ok2 := targetType.(*types.IntType); ok2 {
-			// Float to signed int
+			if dstUnsigned {
+				return ctx.Block.NewFPToUI(val, targetType), nil
+			}
 			return ctx.Block.NewFPToSI(val, targetType), nil
 		}
 	}
@@ -512,6 +2376,53 @@ func (e *ExplicitCast) Codegen(ctx *CodegenContext) (value.Value, error) {
 }
 
 func (i *IndexExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
+	if i.SliceEnd != nil {
+		return i.codegenSlice(ctx)
+	}
+	gep, err := i.indexPtr(false, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.Block.NewLoad(gep), nil
+}
+
+// a[i..j] views the elements i to j of a without copying them, they keep their indices
+func (i *IndexExpr) codegenSlice(ctx *CodegenContext) (value.Value, error) {
+	arr, err := ctx.openArray(i.Collection)
+	if err != nil {
+		return nil, err
+	}
+	start, err := ctx.boundValue(i.Indices[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := ctx.boundValue(i.SliceEnd)
+	if err != nil {
+		return nil, err
+	}
+	lower := ctx.Block.NewExtractValue(arr, 1)
+	upper := ctx.Block.NewExtractValue(arr, 2)
+	// An empty slice like a[3..2] is fine as long as it stays within the array
+	inside := ctx.Block.NewAnd(ctx.Block.NewICmp(enum.IPredSGE, start, lower), ctx.Block.NewICmp(enum.IPredSLE, end, upper))
+	notReversed := ctx.Block.NewICmp(enum.IPredSLE, start, ctx.Block.NewAdd(end, constant.NewInt(types.I64, 1)))
+	err = ctx.boundsCheck(ctx.Block.NewAnd(inside, notReversed))
+	if err != nil {
+		return nil, err
+	}
+	data := ctx.Block.NewGetElementPtr(ctx.Block.NewExtractValue(arr, 0), ctx.Block.NewSub(start, lower))
+	view := ctx.Block.NewInsertValue(constant.NewUndef(arr.Type()), data, 0)
+	view = ctx.Block.NewInsertValue(view, start, 1)
+	return ctx.Block.NewInsertValue(view, end, 2), nil
+}
+
+// Computes the address of the indexed element, emitting bounds checks.
+// Assigning to a missing map key adds it
+func (i *IndexExpr) elementPtr(ctx *CodegenContext) (value.Value, error) {
+	return i.indexPtr(true, ctx)
+}
+
+// insert tells whether a missing map key gets a new entry or stops the program
+func (i *IndexExpr) indexPtr(insert bool, ctx *CodegenContext) (value.Value, error) {
 	typ := getExprType(i.Collection)
 	containerType, ok := typ.(*ContainerType)
 	if !ok {
@@ -519,20 +2430,55 @@ func (i *IndexExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
 		return nil, NewLangError(InvalidArrayAccessType).At(line, col)
 	}
 
-	alloca, err := i.Collection.Codegen(ctx)
-	if err != nil {
-		return nil, err
+	var alloca value.Value
+	switch collec := i.Collection.(type) {
+	case *IndexExpr:
+		if collec.SliceEnd != nil {
+			slice, err := collec.codegenSlice(ctx)
+			if err != nil {
+				return nil, err
+			}
+			alloca = ctx.Block.NewAlloca(slice.Type())
+			ctx.Block.NewStore(slice, alloca)
+			break
+		}
+		// Reading m[k][j] must not add k to m
+		ptr, err := collec.indexPtr(insert, ctx)
+		if err != nil {
+			return nil, err
+		}
+		alloca = ptr
+	case *Identifier, *FieldExpr:
+		// Index directly into the variable's storage so stores are visible
+		ptr, err := addressOf(collec, ctx)
+		if err != nil {
+			return nil, err
+		}
+		alloca = ptr
+	default:
+		val, err := i.Collection.Codegen(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// If the collection is a temporary value, allocate space for it and store it in memory
+		tempAlloca := ctx.Block.NewAlloca(val.Type())
+		ctx.Block.NewStore(val, tempAlloca)
+		alloca = tempAlloca
 	}
-
-	// FIXME: This is prob not optimized
-
-	// If alloca is not a pointer, allocate space for it and store it in memory
-	if _, ok := alloca.Type().(*types.PointerType); !ok {
-		tempAlloca := ctx.Block.NewAlloca(alloca.Type()) // Allocate space for the array
-		ctx.Block.NewStore(alloca, tempAlloca)           // Store the array in memory
-		alloca = tempAlloca                              // Update alloca to point to the allocated memory
+	if containerType.Kind == ContainerHashMap {
+		return i.mapValuePtr(alloca, containerType, insert, ctx)
+	}
+	if containerType.IsDynamic {
+		return i.dynamicElementPtr(alloca, ctx)
+	}
+	if containerType.IsRuntimeSized {
+		return i.runtimeElementPtr(alloca, ctx)
 	}
 
+	bounds, err := constBounds(containerType, ctx)
+	if err != nil {
+		return nil, err
+	}
 	indices := []value.Value{constant.NewInt(types.I64, 0)}
 	for j, index := range i.Indices {
 		indexVal, err := index.Codegen(ctx) // Should probably be an integer value
@@ -540,77 +2486,189 @@ func (i *IndexExpr) Codegen(ctx *CodegenContext) (value.Value, error) {
 			return nil, err
 		}
 
-		_, ok := indexVal.Type().(*types.IntType)
+		indexInt, ok := indexVal.Type().(*types.IntType)
 		if !ok {
 			line, col := index.Pos()
 			return nil, fmt.Errorf("[Dòng %d, Cột %d] Chỉ số của mảng phải là số nguyên", line, col) // TODO: Maybe add proper error type later
 		}
+		// Bounds are 64 bit, so widen the index before comparing
+		if indexInt.BitSize < 64 {
+			indexVal = ctx.castInt(indexVal, types.I64, isTypeUnsigned_Type(getExprType(index)))
+		}
 
-		lowerBoundVal, err := containerType.Bounds[j].Codegen(ctx) // Should probably be an integer value
+		// Each dimension is checked against its own bounds
+		lowerBound := constant.NewInt(types.I64, bounds[j][0])
+		upperBound := constant.NewInt(types.I64, bounds[j][1])
+		icmpLower := ctx.Block.NewICmp(enum.IPredSGE, indexVal, lowerBound)
+		icmpUpper := ctx.Block.NewICmp(enum.IPredSLE, indexVal, upperBound)
+		inBounds := ctx.Block.NewAnd(icmpLower, icmpUpper)
+		err = ctx.boundsCheck(inBounds)
 		if err != nil {
 			return nil, err
 		}
-		upperBoundVal, err := containerType.Bounds[j+1].Codegen(ctx)
+
+		offset := ctx.Block.NewSub(indexVal, lowerBound)
+		indices = append(indices, offset)
+	}
+	return ctx.Block.NewGetElementPtr(alloca, indices...), nil
+}
+
+// Runtime sized containers check each index against the bounds stored with them
+func (i *IndexExpr) runtimeElementPtr(arrPtr value.Value, ctx *CodegenContext) (value.Value, error) {
+	arr := ctx.Block.NewLoad(arrPtr)
+	var offset value.Value = constant.NewInt(types.I64, 0)
+	for d, index := range i.Indices {
+		indexVal, err := index.Codegen(ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		lowerBound, ok1 := lowerBoundVal.(*constant.Int)
-		upperBound, ok2 := upperBoundVal.(*constant.Int)
-		if !ok1 || !ok2 {
-			return nil, errors.New("giới hạn của mảng phải là số nguyên")
+		indexVal = ctx.castInt(indexVal, types.I64, isTypeUnsigned_Type(getExprType(index)))
+		lower := ctx.Block.NewExtractValue(arr, uint64(1+2*d))
+		upper := ctx.Block.NewExtractValue(arr, uint64(2+2*d))
+		inBounds := ctx.Block.NewAnd(ctx.Block.NewICmp(enum.IPredSGE, indexVal, lower), ctx.Block.NewICmp(enum.IPredSLE, indexVal, upper))
+		err = ctx.boundsCheck(inBounds)
+		if err != nil {
+			return nil, err
 		}
+		// Row by row, each earlier dimension counts whole rows
+		offset = ctx.Block.NewAdd(ctx.Block.NewMul(offset, ctx.runtimeLength(arr, d)), ctx.Block.NewSub(indexVal, lower))
+	}
+	return ctx.Block.NewGetElementPtr(ctx.Block.NewExtractValue(arr, 0), offset), nil
+}
 
-		icmpLower := ctx.Block.NewICmp(enum.IPredSGE, indexVal, lowerBound)
-		icmpUpper := ctx.Block.NewICmp(enum.IPredSLE, indexVal, upperBound)
-		inBounds := ctx.Block.NewAnd(icmpLower, icmpUpper)
+// Maps are indexed by key. Reading a missing key stops the program
+func (i *IndexExpr) mapValuePtr(mapPtr value.Value, mapType *ContainerType, insert bool, ctx *CodegenContext) (value.Value, error) {
+	keyVal, err := i.Indices[0].Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	valueType, err := llvmTypeFromType(mapType.ElementType, ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, str := ctx.mapKey(keyVal, mapType.KeyType)
+	if insert {
+		slot := ctx.Block.NewCall(ctx.mapInsertFunc(), mapPtr, key, str, sizeOf(valueType))
+		return ctx.Block.NewBitCast(slot, types.NewPointer(valueType)), nil
+	}
 
-		// Control flow
-		flowID := ctx.NextFlowID()
-		fail := ctx.Func.NewBlock(fmt.Sprintf("fail.%d", flowID))
-		cont := ctx.Func.NewBlock(fmt.Sprintf("cont.%d", flowID))
-		ctx.Block.NewCondBr(inBounds, cont, fail)
-
-		ctx.Block = fail
-		oobstr := findGlobal(ctx.Module, ".errstr_array_oob")
-		if oobstr == nil {
-			return nil, errors.New("không tìm thấy chuỗi mã lỗi")
-		}
-		strPtr := ctx.Block.NewGetElementPtr(
-			oobstr,
-			constant.NewInt(types.I64, 0), // struct index
-			constant.NewInt(types.I64, 0), // char* offset
-		)
-		puts := findFunction(ctx.Module, "puts")
-		if puts == nil {
-			return nil, errors.New("không tìm thấy hàm ngoại 'puts()'")
-		}
-		exit := findFunction(ctx.Module, "exit")
-		if exit == nil {
-			return nil, errors.New("không tìm thấy hàm ngoại 'exit()'")
-		}
-		ctx.Block.NewCall(puts, strPtr)
-		ctx.Block.NewCall(exit, constant.NewInt(types.I32, 1))
-		ctx.Block.NewUnreachable()
-		ctx.Block = cont
+	index := ctx.Block.NewCall(ctx.mapFindFunc(), mapPtr, key, str)
+	err = ctx.runtimeCheck(ctx.Block.NewICmp(enum.IPredNE, index, constant.NewInt(types.I64, -1)), ".errstr_map_key")
+	if err != nil {
+		return nil, err
+	}
+	return ctx.mapValueAt(mapPtr, index, valueType), nil
+}
 
-		offset := ctx.Block.NewSub(indexVal, lowerBound)
-		indices = append(indices, offset)
+// Address of the value in slot index
+func (ctx *CodegenContext) mapValueAt(mapPtr, index value.Value, valueType types.Type) value.Value {
+	values := ctx.Block.NewLoad(ctx.mapField(mapPtr, mapValues))
+	slot := ctx.Block.NewGetElementPtr(values, ctx.Block.NewMul(index, sizeOf(valueType)))
+	return ctx.Block.NewBitCast(slot, types.NewPointer(valueType))
+}
+
+// Keys are passed to the runtime as i64, together with whether they are strings
+func (ctx *CodegenContext) mapKey(key value.Value, keyType Type) (value.Value, value.Value) {
+	if _, ok := key.Type().(*types.PointerType); ok {
+		return ctx.Block.NewPtrToInt(key, types.I64), constant.True
 	}
-	gep := ctx.Block.NewGetElementPtr(alloca, indices...)
-	return ctx.Block.NewLoad(gep), nil
+	// B1 and characters have no sign
+	unsigned := !isTypeInteger_Type(keyType) || isTypeUnsigned_Type(keyType)
+	return ctx.castInt(key, types.I64, unsigned), constant.False
+}
+
+// Dynamic arrays are indexed from 0 up to their current length
+func (i *IndexExpr) dynamicElementPtr(arrPtr value.Value, ctx *CodegenContext) (value.Value, error) {
+	indexVal, err := i.Indices[0].Codegen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexInt, ok := indexVal.Type().(*types.IntType)
+	if !ok {
+		line, col := i.Indices[0].Pos()
+		return nil, NewLangError(InvalidArrayAccessIndex).At(line, col)
+	}
+	if indexInt.BitSize < 64 {
+		indexVal = ctx.castInt(indexVal, types.I64, isTypeUnsigned_Type(getExprType(i.Indices[0])))
+	}
+
+	zero := constant.NewInt(types.I32, 0)
+	length := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(arrPtr, zero, constant.NewInt(types.I32, 1)))
+	// A negative index wraps around to a huge unsigned number, so one compare covers both ends
+	err = ctx.boundsCheck(ctx.Block.NewICmp(enum.IPredULT, indexVal, length))
+	if err != nil {
+		return nil, err
+	}
+	data := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(arrPtr, zero, zero))
+	return ctx.Block.NewGetElementPtr(data, indexVal), nil
+}
+
+// Continues in a new block when inBounds holds, otherwise reports and exits
+func (ctx *CodegenContext) boundsCheck(inBounds value.Value) error {
+	return ctx.runtimeCheck(inBounds, ".errstr_array_oob")
+}
+
+// Prints the error string named errName and exits when ok is false
+func (ctx *CodegenContext) runtimeCheck(ok value.Value, errName string) error {
+	flowID := ctx.NextFlowID()
+	fail := ctx.Func.NewBlock(fmt.Sprintf("fail.%d", flowID))
+	cont := ctx.Func.NewBlock(fmt.Sprintf("cont.%d", flowID))
+	ctx.Block.NewCondBr(ok, cont, fail)
+
+	ctx.Block = fail
+	errstr := findGlobal(ctx.Module, errName)
+	if errstr == nil {
+		return errors.New("không tìm thấy chuỗi mã lỗi")
+	}
+	strPtr := ctx.Block.NewGetElementPtr(
+		errstr,
+		constant.NewInt(types.I64, 0), // struct index
+		constant.NewInt(types.I64, 0), // char* offset
+	)
+	puts := findFunction(ctx.Module, "puts")
+	if puts == nil {
+		return errors.New("không tìm thấy hàm ngoại 'puts()'")
+	}
+	exit := findFunction(ctx.Module, "exit")
+	if exit == nil {
+		return errors.New("không tìm thấy hàm ngoại 'exit()'")
+	}
+	ctx.Block.NewCall(puts, strPtr)
+	ctx.Block.NewCall(exit, constant.NewInt(types.I32, 1))
+	ctx.Block.NewUnreachable()
+	ctx.Block = cont
+	return nil
 }
 
 func GenerateLLVMIR(prog *Program) (*ir.Module, error) {
 	ctx := &CodegenContext{
 		Module:        ir.NewModule(),
 		Symbols:       make(map[string]value.Value),
+		Globals:       make(map[string]value.Value),
+		Structs:       make(map[string]types.Type),
+		Functions:     make(map[string]*Function),
 		ifIDCounter:   0,
+		loopIDCounter: 0,
 		flowIDCounter: 0,
+		strIDCounter:  0,
+		arrIDCounter:  0,
+		strings:       make(map[string]*ir.Global),
 	}
 
 	declareRuntimeHelper(ctx.Module) // Declare external functions like printf(), puts(), exit()
 	ctx.DeclareGlobal()
+	for _, global := range prog.Globals {
+		err := global.CodegenGlobal(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, fn := range prog.Functions {
+		_, err := fn.Declare(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
 	for _, fn := range prog.Functions {
 		_, err := fn.Codegen(ctx)
 		if err != nil {
@@ -630,44 +2688,107 @@ func llvmTypeFromType(typ Type, ctx *CodegenContext) (types.Type, error) {
 	switch typ := typ.(type) {
 	case *PrimitiveType:
 		return llvmTypeFromPrimitive(typ)
+	case *StructType:
+		if st, ok := ctx.Structs[typ.Name]; ok {
+			return st, nil
+		}
+		fields := make([]types.Type, len(typ.Order))
+		for i, name := range typ.Order {
+			fieldType, err := llvmTypeFromType(typ.Fields[name], ctx)
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = fieldType
+		}
+		st := ctx.Module.NewTypeDef(typ.Name, types.NewStruct(fields...))
+		ctx.Structs[typ.Name] = st
+		return st, nil
 	case *ContainerType:
 		elemType, err := llvmTypeFromType(typ.ElementType, ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		// Only supports regular array for now
-		if len(typ.Bounds) != 2 {
-			fmt.Printf("%+v\n", typ.Bounds)
-			return nil, errors.New("mảng phải có đúng 2 giới hạn (sàn và trần)")
+		if typ.Kind == ContainerHashMap {
+			return hashMapType(), nil
+		}
+		if typ.IsRuntimeSized {
+			return runtimeArrayType(elemType, typ.Dimensions), nil
+		}
+		if typ.IsDynamic {
+			if typ.Kind != ContainerArray {
+				return nil, NewLangError(TypeMismatch, typ.String(), "kiểu được LLVM hỗ trợ")
+			}
+			return dynamicArrayType(elemType), nil
 		}
 
-		lowerBoundVal, err := typ.Bounds[0].Codegen(ctx)
+		// Nested arrays, the first dimension is the outermost
+		bounds, err := constBounds(typ, ctx)
 		if err != nil {
 			return nil, err
 		}
-		upperBoundVal, err := typ.Bounds[1].Codegen(ctx)
-		if err != nil {
-			return nil, err
+		var arrType types.Type = elemType
+		for d := len(bounds) - 1; d >= 0; d-- {
+			arrType = types.NewArray(uint64(bounds[d][1]-bounds[d][0]+1), arrType)
 		}
+		return arrType, nil
+	default:
+		return nil, NewLangError(TypeMismatch, typ.String(), "kiểu được LLVM hỗ trợ")
+	}
+}
 
-		lowerConst, lok := lowerBoundVal.(*constant.Int)
-		upperConst, uok := upperBoundVal.(*constant.Int)
-		if !lok || !uok {
-			return nil, errors.New("giới hạn mảng phải là số nguyên")
+// Evaluates the bounds of a fixed size container, one [lower, upper] pair per dimension
+func constBounds(typ *ContainerType, ctx *CodegenContext) ([][2]int64, error) {
+	if len(typ.Bounds) != 2*typ.Dimensions {
+		return nil, fmt.Errorf("%s cần %d giới hạn (sàn và trần) thay vì %d", typ.Kind, 2*typ.Dimensions, len(typ.Bounds))
+	}
+	bounds := make([][2]int64, typ.Dimensions)
+	for d := range bounds {
+		for k := range 2 {
+			val, err := typ.Bounds[2*d+k].Codegen(ctx)
+			if err != nil {
+				return nil, err
+			}
+			constVal, ok := val.(*constant.Int)
+			if !ok {
+				return nil, errors.New("giới hạn mảng phải là số nguyên")
+			}
+			bounds[d][k] = constVal.X.Int64()
 		}
-
-		lowerBound := lowerConst.X.Int64()
-		upperBound := upperConst.X.Int64()
-		if lowerBound > upperBound {
-			return nil, fmt.Errorf("giới hạn sàn (%d) cao hơn giới hạn trần (%d)", lowerBound, upperBound)
+		if bounds[d][0] > bounds[d][1] {
+			return nil, fmt.Errorf("giới hạn sàn (%d) cao hơn giới hạn trần (%d)", bounds[d][0], bounds[d][1])
 		}
+	}
+	return bounds, nil
+}
 
-		length := upperBound - lowerBound + 1
-		return types.NewArray(uint64(length), elemType), nil
-	default:
-		return nil, NewLangError(TypeMismatch, typ.String(), "kiểu được LLVM hỗ trợ")
+// Dynamic arrays are { data, length, capacity }. A capacity of 0 with a non zero
+// length means the data isn't on the heap (global initializers) and is copied on growth
+func dynamicArrayType(elemType types.Type) *types.StructType {
+	return types.NewStruct(types.NewPointer(elemType), types.I64, types.I64)
+}
+
+// Runtime sized containers are { data, lower0, upper0, lower1, upper1, ... }
+// with the elements on the heap, row by row
+func runtimeArrayType(elemType types.Type, dimensions int) *types.StructType {
+	fields := []types.Type{types.NewPointer(elemType)}
+	for range 2 * dimensions {
+		fields = append(fields, types.I64)
 	}
+	return types.NewStruct(fields...)
+}
+
+// Hash maps use open addressing with linear probing, laid out as
+// { i64* keys, i8* states, i8* values, i64 length, i64 used, i64 capacity }.
+// Keys are kept as i64, strings by their address, and values are stored untyped.
+// used counts removed slots too. A zeroed map is empty
+func hashMapType() *types.StructType {
+	return types.NewStruct(types.NewPointer(types.I64), types.I8Ptr, types.I8Ptr, types.I64, types.I64, types.I64)
+}
+
+// Size of a type in bytes, as the address of element 1 in an array starting at null
+func sizeOf(typ types.Type) constant.Constant {
+	null := constant.NewNull(types.NewPointer(typ))
+	return constant.NewPtrToInt(constant.NewGetElementPtr(null, constant.NewInt(types.I32, 1)), types.I64)
 }
 
 func llvmTypeFromPrimitive(typ Type) (types.Type, error) {
@@ -676,7 +2797,11 @@ func llvmTypeFromPrimitive(typ Type) (types.Type, error) {
 		switch typ.Name {
 		case PrimitiveB1:
 			return types.I1, nil
-		case PrimitiveN32, PrimitiveZ32:
+		case PrimitiveC8:
+			return types.I8, nil
+		case PrimitiveC16:
+			return types.I16, nil
+		case PrimitiveN32, PrimitiveZ32, PrimitiveC32:
 			return types.I32, nil
 		case PrimitiveN64, PrimitiveZ64:
 			return types.I64, nil
@@ -684,6 +2809,8 @@ func llvmTypeFromPrimitive(typ Type) (types.Type, error) {
 			return types.Float, nil
 		case PrimitiveR64:
 			return types.Double, nil
+		case PrimitiveS8:
+			return types.I8Ptr, nil
 		case PrimitiveVoid:
 			return types.Void, nil
 		default:
@@ -694,6 +2821,64 @@ func llvmTypeFromPrimitive(typ Type) (types.Type, error) {
 	}
 }
 
+// Widens or truncates an integer value to the given integer type
+func (ctx *CodegenContext) castInt(val value.Value, to types.Type, unsigned bool) value.Value {
+	from, ok1 := val.Type().(*types.IntType)
+	dst, ok2 := to.(*types.IntType)
+	if !ok1 || !ok2 || from.BitSize == dst.BitSize {
+		return val
+	}
+	if from.BitSize > dst.BitSize {
+		return ctx.Block.NewTrunc(val, dst)
+	}
+	if unsigned {
+		return ctx.Block.NewZExt(val, dst)
+	}
+	return ctx.Block.NewSExt(val, dst)
+}
+
+// Returns the memory location of an assignable expression
+func addressOf(expr Expression, ctx *CodegenContext) (value.Value, error) {
+	switch e := expr.(type) {
+	case *Identifier:
+		// Locals shadow globals
+		if alloca, ok := ctx.Symbols[e.Name]; ok {
+			return alloca, nil
+		}
+		if global, ok := ctx.Globals[e.Name]; ok {
+			return global, nil
+		}
+		// FIXME: Handle this differently
+		return nil, fmt.Errorf("unknown variable %s", e.Name)
+	case *IndexExpr:
+		if e.SliceEnd != nil {
+			return nil, NewLangError(InvalidAssignTarget).At(e.Line, e.Column)
+		}
+		return e.elementPtr(ctx)
+	case *FieldExpr:
+		var base value.Value
+		switch e.Object.(type) {
+		case *Identifier, *IndexExpr, *FieldExpr:
+			ptr, err := addressOf(e.Object, ctx)
+			if err != nil {
+				return nil, err
+			}
+			base = ptr
+		default:
+			val, err := e.Object.Codegen(ctx)
+			if err != nil {
+				return nil, err
+			}
+			base = ctx.Block.NewAlloca(val.Type())
+			ctx.Block.NewStore(val, base)
+		}
+		return ctx.Block.NewGetElementPtr(base, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(e.Index))), nil
+	default:
+		line, col := expr.Pos()
+		return nil, NewLangError(InvalidAssignTarget).At(line, col)
+	}
+}
+
 func findFunction(module *ir.Module, funcName string) *ir.Func {
 	for _, fn := range module.Funcs {
 		if fn.Name() == funcName {
@@ -717,13 +2902,16 @@ func canICmp(left value.Value, right value.Value) bool {
 	rightType := right.Type()
 
 	if leftType.Equal(rightType) {
-		if leftType.Equal(types.I32) || leftType.Equal(types.I64) {
-			return true
-		}
+		_, ok := leftType.(*types.IntType)
+		return ok
 	}
 	return false
 }
 
+func canStrCmp(left value.Value, right value.Value) bool {
+	return left.Type().Equal(types.I8Ptr) && right.Type().Equal(types.I8Ptr)
+}
+
 func canFCmp(left value.Value, right value.Value) bool {
 	leftType := left.Type()
 	rightType := right.Type()
@@ -741,6 +2929,21 @@ func (ctx *CodegenContext) NextIfID() int {
 	return ctx.ifIDCounter
 }
 
+func (ctx *CodegenContext) NextLoopID() int {
+	ctx.loopIDCounter++
+	return ctx.loopIDCounter
+}
+
+func (ctx *CodegenContext) NextArrID() int {
+	ctx.arrIDCounter++
+	return ctx.arrIDCounter
+}
+
+func (ctx *CodegenContext) NextStrID() int {
+	ctx.strIDCounter++
+	return ctx.strIDCounter
+}
+
 func (ctx *CodegenContext) NextFlowID() int {
 	ctx.flowIDCounter++
 	return ctx.flowIDCounter
@@ -763,6 +2966,17 @@ func (ctx *CodegenContext) GetOrCreateGlobalString(name, value string) *ir.Globa
 func (ctx *CodegenContext) DeclareGlobal() {
 	// Create error string for out of bound array access
 	ctx.Module.NewGlobalDef(".errstr_array_oob", constant.NewCharArrayFromString("chỉ số của mảng nằm ngoài giới hạn\n"))
+	// Matrices whose shapes are only known at runtime
+	ctx.Module.NewGlobalDef(".errstr_matrix_shape", constant.NewCharArrayFromString("kích thước của ma trận không khớp\n"))
+	// Reading a key that isn't in a map
+	ctx.Module.NewGlobalDef(".errstr_map_key", constant.NewCharArrayFromString("khoá không có trong bảng băm\n"))
+	// Containers sized at runtime
+	ctx.Module.NewGlobalDef(".errstr_array_bounds", constant.NewCharArrayFromString("giới hạn sàn của mảng cao hơn giới hạn trần\n"))
+	ctx.Module.NewGlobalDef(".errstr_array_size", constant.NewCharArrayFromString("kích thước của mảng không khớp\n"))
+	// A "bước" that is only known at runtime
+	ctx.Module.NewGlobalDef(".errstr_loop_step", constant.NewCharArrayFromString("bước nhảy của vòng lặp phải là số dương\n"))
+	// nhỏ_nhất and lớn_nhất of an empty array
+	ctx.Module.NewGlobalDef(".errstr_array_empty", constant.NewCharArrayFromString("mảng rỗng không có phần tử\n"))
 }
 
 func declareRuntimeHelper(mod *ir.Module) {
@@ -776,4 +2990,23 @@ func declareRuntimeHelper(mod *ir.Module) {
 	// Exit code
 	exit := mod.NewFunc("exit", types.Void, ir.NewParam("status", types.I32))
 	exit.Linkage = enum.LinkageExternal
+	// String comparison
+	strcmp := mod.NewFunc("strcmp", types.I32, ir.NewParam("", types.I8Ptr), ir.NewParam("", types.I8Ptr))
+	strcmp.Linkage = enum.LinkageExternal
+	// Heap storage for dynamic arrays
+	malloc := mod.NewFunc("malloc", types.I8Ptr, ir.NewParam("size", types.I64))
+	malloc.Linkage = enum.LinkageExternal
+	realloc := mod.NewFunc("realloc", types.I8Ptr, ir.NewParam("ptr", types.I8Ptr), ir.NewParam("size", types.I64))
+	realloc.Linkage = enum.LinkageExternal
+	memcpy := mod.NewFunc("memcpy", types.I8Ptr, ir.NewParam("dest", types.I8Ptr), ir.NewParam("src", types.I8Ptr), ir.NewParam("n", types.I64))
+	memcpy.Linkage = enum.LinkageExternal
+	// Zeroed storage for hash maps
+	calloc := mod.NewFunc("calloc", types.I8Ptr, ir.NewParam("count", types.I64), ir.NewParam("size", types.I64))
+	calloc.Linkage = enum.LinkageExternal
+	memmove := mod.NewFunc("memmove", types.I8Ptr, ir.NewParam("dest", types.I8Ptr), ir.NewParam("src", types.I8Ptr), ir.NewParam("n", types.I64))
+	memmove.Linkage = enum.LinkageExternal
+	memset := mod.NewFunc("memset", types.I8Ptr, ir.NewParam("dest", types.I8Ptr), ir.NewParam("c", types.I32), ir.NewParam("n", types.I64))
+	memset.Linkage = enum.LinkageExternal
+	free := mod.NewFunc("free", types.Void, ir.NewParam("ptr", types.I8Ptr))
+	free.Linkage = enum.LinkageExternal
 }
@@ -26,30 +26,96 @@ const (
 	InvalidArrayAccessType
 	InvalidArrayAccessDim
 	InvalidArrayAccessRange
+	InvalidAssignTarget
+	NonConstantGlobal
+	InitializerCountMismatch
+	RedeclarationStruct
+	RedeclarationField
+	UnknownStructType
+	UnknownStructField
+	InvalidFieldAccess
+	RecursiveStruct
+	UnterminatedString
+	InvalidEscape
+	InvalidOperand
+	InvalidLoopBound
+	InvalidLoopStep
+	LoopControlOutsideLoop
+	UnknownLoopLabel
+	RedeclarationLoopLabel
+	LiteralOutOfRange
+	UnterminatedChar
+	InvalidCharLiteral
+	InvalidBuiltinArgument
+	MatrixShapeMismatch
+	MatrixProductMismatch
+	InvalidMatrixSize
+	InvalidMapKey
+	InvalidArrayBound
+	RuntimeBoundNotAllowed
+	OpenArrayNotDynamic
+	ForEachRangeIndex
+	ForEachNotIterable
+	InvalidSlice
+	UnorderedElement
+	DynamicMatrix
 )
 
 var errorMessagesVi = map[ErrorID]string{
-	ExpectToken:             "Mong đợi %v ở vị trí này.",
-	UnexpectedToken:         "Không mong đợi ký hiệu '%v' ở vị trí này.",
-	WrongToken:              "Mong đợi ký hiệu '%v' thay vì '%v' ở vị trí này.",
-	TypeMismatch:            "Sai kiểu '%v' thay vì '%v'.",
-	ReturnTypeMismatch:      "Không thể trả về giá trị kiểu '%v', mong đợi kiểu '%v'.",
-	UndeclaredIdentifier:    "Không tìm thấy định danh '%v'.",
-	MissingReturn:           "Thiếu câu lệnh trả về trong hàm có kiểu trả về '%v'.",
-	InvalidFunctionCall:     "Hàm '%v' không tồn tại",
-	ArgumentCountMismatch:   "Số lượng đối số (%v) của lời gọi hàm không khớp với số lượng tham số (%v) của hàm '%v'.",
-	ArgumentTypeMismatch:    "Đối số '%v' khác kiểu với tham số '%v'.",
-	RedeclarationVar:        "Lỗi khai báo lại biến '%v'.",
-	RedeclarationFunction:   "Lỗi khai báo lại hàm '%v'.",
-	UnknownExpression:       "Biểu thức không xác định.",
-	InvalidIdentifierUsage:  "Không thể đánh giá được cách sử dụng ký hiệu '%v'.",
-	UnknownIdentifierType:   "Ký hiệu không xác định.",
-	InvalidCasting:          "Không thể chuyển kiểu '%v' sang kiểu '%v'.",
-	ErrorBinaryExpr:         "Không thể thực hiện phép toán giữa '%v' và '%v'",
-	InvalidArrayAccessIndex: "Không thể truy cập phần tử của biến này với chỉ số khác số nguyên",
-	InvalidArrayAccessType:  "Không thể truy cập phần tử của biểu thức này, làm ơn truy cập một biến thuộc kiểu mảng",
-	InvalidArrayAccessDim:   "Chiều của chỉ số (%d) khác với chiều của biến (%d)",
-	InvalidArrayAccessRange: "Chỉ số (%d) nằm ngoài giới hạn của mảng [%d..%d]",
+	ExpectToken:              "Mong đợi %v ở vị trí này.",
+	UnexpectedToken:          "Không mong đợi ký hiệu '%v' ở vị trí này.",
+	WrongToken:               "Mong đợi ký hiệu '%v' thay vì '%v' ở vị trí này.",
+	TypeMismatch:             "Sai kiểu '%v' thay vì '%v'.",
+	ReturnTypeMismatch:       "Không thể trả về giá trị kiểu '%v', mong đợi kiểu '%v'.",
+	UndeclaredIdentifier:     "Không tìm thấy định danh '%v'.",
+	MissingReturn:            "Thiếu câu lệnh trả về trong hàm có kiểu trả về '%v'.",
+	InvalidFunctionCall:      "Hàm '%v' không tồn tại",
+	ArgumentCountMismatch:    "Số lượng đối số (%v) của lời gọi hàm không khớp với số lượng tham số (%v) của hàm '%v'.",
+	ArgumentTypeMismatch:     "Đối số '%v' khác kiểu với tham số '%v'.",
+	RedeclarationVar:         "Lỗi khai báo lại biến '%v'.",
+	RedeclarationFunction:    "Lỗi khai báo lại hàm '%v'.",
+	UnknownExpression:        "Biểu thức không xác định.",
+	InvalidIdentifierUsage:   "Không thể đánh giá được cách sử dụng ký hiệu '%v'.",
+	UnknownIdentifierType:    "Ký hiệu không xác định.",
+	InvalidCasting:           "Không thể chuyển kiểu '%v' sang kiểu '%v'.",
+	ErrorBinaryExpr:          "Không thể thực hiện phép toán giữa '%v' và '%v'",
+	InvalidArrayAccessIndex:  "Không thể truy cập phần tử của biến này với chỉ số khác số nguyên",
+	InvalidArrayAccessType:   "Không thể truy cập phần tử của biểu thức này, làm ơn truy cập một biến thuộc kiểu mảng",
+	InvalidArrayAccessDim:    "Chiều của chỉ số (%d) khác với chiều của biến (%d)",
+	InvalidArrayAccessRange:  "Chỉ số (%d) nằm ngoài giới hạn của mảng [%d..%d]",
+	InvalidAssignTarget:      "Không thể gán giá trị cho biểu thức này, làm ơn gán cho một biến hoặc phần tử của mảng",
+	NonConstantGlobal:        "Giá trị khởi tạo của biến toàn cục '%v' phải là hằng số.",
+	InitializerCountMismatch: "Số lượng giá trị khởi tạo (%v) không khớp với số lượng biến (%v).",
+	RedeclarationStruct:      "Lỗi khai báo lại cấu trúc '%v'.",
+	RedeclarationField:       "Lỗi khai báo lại trường '%v'.",
+	UnknownStructType:        "Không tìm thấy kiểu dữ liệu có cấu trúc '%v'.",
+	UnknownStructField:       "Cấu trúc '%v' không có trường '%v'.",
+	InvalidFieldAccess:       "Không thể truy cập trường '%v' của biểu thức kiểu '%v'.",
+	RecursiveStruct:          "Cấu trúc '%v' không thể chứa chính nó.",
+	UnterminatedString:       "Chuỗi bắt đầu tại đây chưa được đóng lại.",
+	InvalidEscape:            "Ký tự thoát '\\%v' không hợp lệ.",
+	InvalidOperand:           "Không thể dùng toán tử '%v' với kiểu '%v'.",
+	InvalidLoopBound:         "Giới hạn của vòng lặp phải là số nguyên thay vì '%v'.",
+	InvalidLoopStep:          "Bước nhảy của vòng lặp phải là số dương.",
+	LoopControlOutsideLoop:   "Không thể dùng '%v' bên ngoài vòng lặp.",
+	UnknownLoopLabel:         "Không tìm thấy vòng lặp có nhãn '%v'.",
+	RedeclarationLoopLabel:   "Nhãn vòng lặp '%v' đã được dùng cho một vòng lặp bên ngoài.",
+	LiteralOutOfRange:        "Giá trị '%v' nằm ngoài phạm vi của kiểu '%v'.",
+	UnterminatedChar:         "Ký tự chưa được đóng bằng dấu nháy đơn.",
+	InvalidCharLiteral:       "'%v' phải là đúng một ký tự.",
+	InvalidBuiltinArgument:   "Hàm '%v' cần đối số kiểu '%v' thay vì '%v'.",
+	MatrixShapeMismatch:      "Ma trận có kích thước %vx%v thay vì %vx%v.",
+	MatrixProductMismatch:    "Không thể nhân ma trận %vx%v với ma trận %vx%v.",
+	InvalidMatrixSize:        "Kích thước của ma trận phải là một hằng số nguyên dương.",
+	InvalidMapKey:            "Kiểu '%v' không thể làm khoá của bảng băm.",
+	InvalidArrayBound:        "Giới hạn của mảng phải là số nguyên thay vì '%v'.",
+	RuntimeBoundNotAllowed:   "Giới hạn của '%v' ở đây phải là hằng số.",
+	OpenArrayNotDynamic:      "'%v' là tham số mảng mở, không thể dùng như mảng động.",
+	ForEachRangeIndex:        "Vòng lặp qua một khoảng không có chỉ số riêng.",
+	ForEachNotIterable:       "Không thể lặp qua giá trị kiểu '%v', chỉ mảng và ma trận.",
+	InvalidSlice:             "Chỉ có thể cắt mảng thay vì '%v'.",
+	UnorderedElement:         "Hàm '%v' không thể so sánh các phần tử kiểu '%v'.",
+	DynamicMatrix:            "Ma trận '%v' phải có giới hạn cho từng chiều.",
 }
 
 type LangError struct {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "ghi lại các tệp .out trong testdata")
+
+// With BANH_HAP set the test binary is the banh command itself,
+// so every program goes through "hap" like it does for users
+func TestMain(m *testing.M) {
+	if os.Getenv("BANH_HAP") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Times printed by log change on every run
+var logTime = regexp.MustCompile(`(?m)^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// Runs every testdata/*.banh program and compares what it prints with the .out file
+// next to it. Programs that don't compile or stop with an error are compared by their message
+func TestHap(t *testing.T) {
+	if _, err := exec.LookPath("lli"); err != nil {
+		t.Skip("cần 'lli' để chạy chương trình")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs, err := filepath.Glob(filepath.Join("testdata", "*.banh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, program := range programs {
+		name := strings.TrimSuffix(filepath.Base(program), ".banh")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			recipe := "[goi]\nten = \"" + name + "\"\nban = \"0.1\"\n[bandung]\ndiemvao = \"main.banh\"\nxuat = \"./out\"\n"
+			if err := os.WriteFile(filepath.Join(dir, "congthuc.toml"), []byte(recipe), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "main.banh"), source, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command(exe, "hap")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "BANH_HAP=1")
+			output, _ := cmd.CombinedOutput() // Failures are part of the expected output
+			got := logTime.ReplaceAllString(string(output), "")
+
+			golden := strings.TrimSuffix(program, ".banh") + ".out"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s in ra:\n%s\nthay vì:\n%s", program, got, want)
+			}
+		})
+	}
+}
@@ -10,16 +10,41 @@ const (
 	KeywordKetThuc  = "kết thúc"
 	KeywordVa       = "và"
 	KeywordHoac     = "hoặc"
+	KeywordKhong    = "không"
+	KeywordXor      = "xor"
 	KeywordTraVe    = "trả về"
 	KeywordThuTuc   = "thủ tục"
+	KeywordCauTruc  = "cấu trúc"
+	KeywordCho      = "cho"
+	KeywordTu       = "từ"
+	KeywordDen      = "đến"
+	KeywordXuong    = "xuống"
+	KeywordBuoc     = "bước"
+	KeywordDung     = "dừng"
+	KeywordTiepTuc  = "tiếp tục"
+	KeywordDdung    = "đúng"
+	KeywordSai      = "sai"
+	KeywordVoiMoi   = "với mỗi"
+	KeywordTrong    = "trong"
 )
 
 var Keywords = map[string]string{
-	"hàm":  KeywordHam,
-	"biến": KeywordBien,
-	"nếu":  KeywordNeu,
-	"và":   KeywordVa,
-	"hoặc": KeywordHoac,
-	"thì":  KeywordThi,
+	"hàm":   KeywordHam,
+	"biến":  KeywordBien,
+	"nếu":   KeywordNeu,
+	"và":    KeywordVa,
+	"hoặc":  KeywordHoac,
+	"không": KeywordKhong,
+	"xor":   KeywordXor,
+	"cho":   KeywordCho,
+	"từ":    KeywordTu,
+	"đến":   KeywordDen,
+	"xuống": KeywordXuong,
+	"bước":  KeywordBuoc,
+	"dừng":  KeywordDung,
+	"đúng":  KeywordDdung,
+	"sai":   KeywordSai,
+	"thì":   KeywordThi,
+	"trong": KeywordTrong,
 	// Multi-word keywords are handled in the lexer
 }
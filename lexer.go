@@ -1,7 +1,10 @@
 package main
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/text/unicode/norm"
 )
@@ -11,6 +14,7 @@ type Lexer struct {
 	pos   int
 	line  int
 	col   int
+	err   *LangError
 }
 
 func NewLexer(input string) *Lexer {
@@ -41,16 +45,44 @@ func (l *Lexer) NextToken() Token {
 		return l.readKeywordOrIdentifier()
 	}
 
-	// Handles numbers
-	if unicode.IsDigit(ch) || (ch == '-' && unicode.IsDigit(l.peek())) {
+	// Handles numbers, a leading '-' is parsed as a unary operator
+	if unicode.IsDigit(ch) {
 		return Token{Type: TokenNumber, Lexeme: l.readNumber(), Line: l.line, Column: col}
 	}
 
 	// Handle strings
-	if ch == '"' {
+	if ch == '"' || ch == '`' {
+		line := l.line
 		l.pos++
 		l.col++
-		return Token{Type: TokenString, Lexeme: l.readString(), Line: l.line, Column: col}
+		var str string
+		var err *LangError
+		if ch == '"' {
+			str, err = l.readString(line, col)
+		} else {
+			str, err = l.readRawString(line, col)
+		}
+		if err != nil {
+			// Stop lexing, the error is reported through Err()
+			l.err = err
+			l.pos = len(l.input)
+			return Token{Type: TokenEOF, Lexeme: "", Line: l.line, Column: l.col}
+		}
+		return Token{Type: TokenString, Lexeme: str, Line: line, Column: col}
+	}
+
+	// Handle characters
+	if ch == '\'' {
+		line := l.line
+		l.pos++
+		l.col++
+		char, err := l.readCharLiteral(line, col)
+		if err != nil {
+			l.err = err
+			l.pos = len(l.input)
+			return Token{Type: TokenEOF, Lexeme: "", Line: l.line, Column: l.col}
+		}
+		return Token{Type: TokenChar, Lexeme: string(char), Line: line, Column: col}
 	}
 
 	// Handles multi-character tokens
@@ -94,6 +126,15 @@ func (l *Lexer) readKeywordOrIdentifier() Token {
 	if l.matchMultiWordKeyword("thủ", "tục") {
 		return Token{Type: TokenKeyword, Lexeme: KeywordThuTuc, Line: l.line, Column: col}
 	}
+	if l.matchMultiWordKeyword("cấu", "trúc") {
+		return Token{Type: TokenKeyword, Lexeme: KeywordCauTruc, Line: l.line, Column: col}
+	}
+	if l.matchMultiWordKeyword("tiếp", "tục") {
+		return Token{Type: TokenKeyword, Lexeme: KeywordTiepTuc, Line: l.line, Column: col}
+	}
+	if l.matchMultiWordKeyword("với", "mỗi") {
+		return Token{Type: TokenKeyword, Lexeme: KeywordVoiMoi, Line: l.line, Column: col}
+	}
 
 	ident := l.readIdentifier()
 
@@ -175,12 +216,22 @@ func (l *Lexer) readMultiCharSymbol(ch rune) *Token {
 			l.readChar()
 			return &Token{Type: TokenOperator, Lexeme: SymbolLessEqual, Line: l.line, Column: col}
 		}
+		if l.peek() == '<' {
+			l.readChar()
+			l.readChar()
+			return &Token{Type: TokenOperator, Lexeme: SymbolShiftLeft, Line: l.line, Column: col}
+		}
 	case '>':
 		if l.peek() == '=' {
 			l.readChar()
 			l.readChar()
 			return &Token{Type: TokenOperator, Lexeme: SymbolGreaterEqual, Line: l.line, Column: col}
 		}
+		if l.peek() == '>' {
+			l.readChar()
+			l.readChar()
+			return &Token{Type: TokenOperator, Lexeme: SymbolShiftRight, Line: l.line, Column: col}
+		}
 	case ':':
 		if l.peek() == '=' {
 			l.readChar()
@@ -216,10 +267,6 @@ func (l *Lexer) readIdentifier() string {
 func (l *Lexer) readNumber() string {
 	start := l.pos
 	isFloat := false
-	if l.input[l.pos] == '-' {
-		l.pos++
-		l.col++
-	}
 	for l.pos < len(l.input) && (unicode.IsDigit(l.input[l.pos]) || (l.input[l.pos] == '.' && !isFloat)) {
 		if l.input[l.pos] == '.' {
 			if !unicode.IsDigit(l.peek()) {
@@ -234,18 +281,128 @@ func (l *Lexer) readNumber() string {
 	return string(l.input[start:l.pos])
 }
 
-// String handler
-func (l *Lexer) readString() string {
+// String handler, supports \n \t \r \" \\ and \u{...} escapes
+func (l *Lexer) readString(line, col int) (string, *LangError) {
+	var sb strings.Builder
+	for {
+		// Regular strings can't span multiple lines
+		if l.pos >= len(l.input) || l.input[l.pos] == '\n' {
+			return "", NewLangError(UnterminatedString).At(line, col)
+		}
+		ch := l.readChar()
+		if ch == '"' {
+			return sb.String(), nil
+		}
+		if ch != '\\' {
+			sb.WriteRune(ch)
+			continue
+		}
+
+		if l.pos >= len(l.input) {
+			return "", NewLangError(UnterminatedString).At(line, col)
+		}
+		esc, err := l.readEscape('"')
+		if err != nil {
+			return "", err
+		}
+		sb.WriteRune(esc)
+	}
+}
+
+// Reads the part of an escape sequence after the '\\', shared by strings and characters
+func (l *Lexer) readEscape(quote rune) (rune, *LangError) {
+	escLine, escCol := l.line, l.col-1
+	esc := l.readChar()
+	switch esc {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '"', '\'', '\\':
+		return esc, nil
+	case 'u':
+		// Unicode code point in hex, for example \u{1EA1}
+		if l.pos >= len(l.input) || l.input[l.pos] != '{' {
+			return 0, NewLangError(InvalidEscape, "u").At(escLine, escCol)
+		}
+		l.readChar() // Consumes '{'
+		start := l.pos
+		for l.pos < len(l.input) && l.input[l.pos] != '}' && l.input[l.pos] != quote && l.input[l.pos] != '\n' {
+			l.readChar()
+		}
+		digits := string(l.input[start:l.pos])
+		if l.pos >= len(l.input) || l.input[l.pos] != '}' {
+			return 0, NewLangError(InvalidEscape, "u{"+digits).At(escLine, escCol)
+		}
+		l.readChar() // Consumes '}'
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return 0, NewLangError(InvalidEscape, "u{"+digits+"}").At(escLine, escCol)
+		}
+		return rune(code), nil
+	default:
+		return 0, NewLangError(InvalidEscape, string(esc)).At(escLine, escCol)
+	}
+}
+
+// Character handler, for example 'ă' or '\n'. The input is already NFC so
+// letters with diacritics are a single code point
+func (l *Lexer) readCharLiteral(line, col int) (rune, *LangError) {
+	if l.pos >= len(l.input) || l.input[l.pos] == '\n' {
+		return 0, NewLangError(UnterminatedChar).At(line, col)
+	}
+	if l.input[l.pos] == '\'' {
+		return 0, NewLangError(InvalidCharLiteral, "").At(line, col)
+	}
+	ch := l.readChar()
+	if ch == '\\' {
+		if l.pos >= len(l.input) {
+			return 0, NewLangError(UnterminatedChar).At(line, col)
+		}
+		esc, err := l.readEscape('\'')
+		if err != nil {
+			return 0, err
+		}
+		ch = esc
+	}
+	if l.pos < len(l.input) && l.input[l.pos] == '\'' {
+		l.readChar() // Consumes the closing quote
+		return ch, nil
+	}
+
+	// More than one character, read up to the closing quote to report them all
 	start := l.pos
-	// Maybe add support for \n and others
-	for l.pos < len(l.input) && l.input[l.pos] != '"' {
-		l.pos++
-		l.col++
+	for l.pos < len(l.input) && l.input[l.pos] != '\'' && l.input[l.pos] != '\n' {
+		l.readChar()
 	}
-	end := l.pos
-	l.pos++
-	l.col++
-	return string(l.input[start:end])
+	if l.pos >= len(l.input) || l.input[l.pos] != '\'' {
+		return 0, NewLangError(UnterminatedChar).At(line, col)
+	}
+	return 0, NewLangError(InvalidCharLiteral, string(ch)+string(l.input[start:l.pos])).At(line, col)
+}
+
+// Raw string handler, for example `C:\thư mục`, may span multiple lines and has no escapes
+func (l *Lexer) readRawString(line, col int) (string, *LangError) {
+	start := l.pos
+	for l.pos < len(l.input) && l.input[l.pos] != '`' {
+		l.readChar()
+	}
+	if l.pos >= len(l.input) {
+		return "", NewLangError(UnterminatedString).At(line, col)
+	}
+	str := string(l.input[start:l.pos])
+	l.readChar() // Consumes the closing '`'
+	return str, nil
+}
+
+// Returns the first error the lexer ran into, if any
+func (l *Lexer) Err() error {
+	if l.err == nil {
+		return nil
+	}
+	return l.err
 }
 
 // Skip whitespaces
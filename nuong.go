@@ -47,7 +47,7 @@ func nuong() {
 		os.Exit(1)
 	}
 
-	cmd = exec.Command("clang", output+".o", "-o", output)
+	cmd = exec.Command("clang", output+".o", "-o", output, "-lm") // libm for llvm.pow
 	out, err = cmd.CombinedOutput()
 	if err != nil {
 		fmt.Println(string(out))
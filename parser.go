@@ -1,6 +1,9 @@
 package main
 
-import "strings"
+import (
+	"slices"
+	"strings"
+)
 
 var precedences = map[string]int{
 	KeywordHoac:        3,
@@ -11,10 +14,17 @@ var precedences = map[string]int{
 	SymbolLess:         25,
 	SymbolGreaterEqual: 25,
 	SymbolGreater:      25,
+	SymbolPipe:         30, // Bitwise operators bind tighter than comparisons
+	KeywordXor:         35,
+	SymbolAmpersand:    40,
+	SymbolShiftLeft:    45,
+	SymbolShiftRight:   45,
 	SymbolPlus:         50,
 	SymbolMinus:        50,
 	SymbolAsterisk:     100,
 	SymbolSlash:        100,
+	SymbolModulo:       100,
+	SymbolCaret:        150,
 }
 
 type Parser struct {
@@ -66,6 +76,26 @@ func (p *Parser) ParseProgram() (*Program, error) {
 			p.nextToken()
 		}
 		switch p.current.Lexeme {
+		case KeywordBien:
+			decl, err := p.parseVarDecl()
+			if err != nil {
+				return nil, err
+			}
+			switch d := decl.(type) {
+			case *VarDecl:
+				prog.Globals = append(prog.Globals, d)
+			case *VarDeclList:
+				prog.Globals = append(prog.Globals, d.Decls...)
+			}
+			if p.current.Type != TokenNewLine && p.current.Type != TokenEOF {
+				return nil, NewLangError(ExpectToken, "xuống dòng").At(p.current.Line, p.current.Column)
+			}
+		case KeywordCauTruc:
+			decl, err := p.parseStructDecl()
+			if err != nil {
+				return nil, err
+			}
+			prog.Structs = append(prog.Structs, decl)
 		case KeywordHam:
 			fn, err := p.parseFunction()
 			if err != nil {
@@ -91,6 +121,57 @@ func (p *Parser) ParseProgram() (*Program, error) {
 	return prog, nil
 }
 
+func (p *Parser) parseStructDecl() (*StructDecl, error) {
+	// Expect 'cấu trúc' keyword
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordCauTruc {
+		return nil, NewLangError(WrongToken, KeywordCauTruc, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'cấu trúc'
+
+	// Expect struct name (identifier)
+	if p.current.Type != TokenIdent {
+		return nil, NewLangError(ExpectToken, "tên cấu trúc").At(p.current.Line, p.current.Column)
+	}
+	name := p.current.Lexeme
+	line, col := p.current.Line, p.current.Column
+	p.nextToken()
+
+	// Forces you to create a new line
+	if p.current.Type != TokenNewLine {
+		return nil, NewLangError(ExpectToken, "xuống dòng").At(p.current.Line, p.current.Column)
+	}
+	for p.current.Type == TokenNewLine {
+		p.nextToken()
+	}
+
+	// One or more fields per line, for example "x, y E R64"
+	fields := []*StructField{}
+	for !(p.current.Type == TokenKeyword && p.current.Lexeme == KeywordKetThuc) && p.current.Type != TokenEOF {
+		vars, err := p.parseVarIdents()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vars {
+			fields = append(fields, &StructField{Name: v.Name, Type: v.Type, Line: v.Line, Column: v.Column})
+		}
+		if p.current.Type != TokenNewLine && p.current.Type != TokenSemiColon {
+			return nil, NewLangError(ExpectToken, "xuống dòng hoặc ';'").At(p.current.Line, p.current.Column)
+		}
+		p.nextToken()
+		for p.current.Type == TokenNewLine {
+			p.nextToken()
+		}
+	}
+
+	// Expect 'kết thúc'
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordKetThuc {
+		return nil, NewLangError(WrongToken, KeywordKetThuc, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken()
+
+	return &StructDecl{Name: name, Fields: fields, Line: line, Column: col}, nil
+}
+
 func (p *Parser) parseProcedure() (*Function, error) {
 	// Expect 'thủ tục' keyword
 	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordThuTuc {
@@ -115,13 +196,11 @@ func (p *Parser) parseProcedure() (*Function, error) {
 	params := []*Variable{}
 	if p.current.Type != TokenRParen {
 		for {
-			line := p.current.Line
-			col := p.current.Column
-			paramName, paramType, err := p.parseVarIdent()
+			vars, err := p.parseVarIdents()
 			if err != nil {
 				return nil, err
 			}
-			params = append(params, &Variable{Name: paramName, Type: paramType, Line: line, Column: col})
+			params = append(params, vars...)
 
 			if p.current.Type == TokenComma {
 				p.nextToken()
@@ -204,13 +283,11 @@ func (p *Parser) parseFunction() (*Function, error) {
 	params := []*Variable{}
 	if p.current.Type != TokenRParen {
 		for {
-			line := p.current.Line
-			col := p.current.Column
-			paramName, paramType, err := p.parseVarIdent()
+			vars, err := p.parseVarIdents()
 			if err != nil {
 				return nil, err
 			}
-			params = append(params, &Variable{Name: paramName, Type: paramType, Line: line, Column: col})
+			params = append(params, vars...)
 
 			if p.current.Type == TokenComma {
 				p.nextToken()
@@ -229,7 +306,7 @@ func (p *Parser) parseFunction() (*Function, error) {
 		return nil, NewLangError(WrongToken, "->", p.current.Lexeme).At(p.current.Line, p.current.Column)
 	}
 	p.nextToken() // Consumes '->'
-	if p.current.Type != TokenIdent && p.current.Type != TokenPrimitive {
+	if p.current.Type != TokenIdent && p.current.Type != TokenPrimitive && p.current.Type != TokenContainer {
 		return nil, NewLangError(ExpectToken, "kiểu trả về").At(p.current.Line, p.current.Column)
 	}
 	returnType, err := p.parseType()
@@ -285,6 +362,14 @@ func (p *Parser) parseStatement() (Statement, error) {
 		switch p.current.Lexeme {
 		case KeywordNeu:
 			return p.parseIfStmt()
+		case KeywordTrongKhi: // while loop
+			return p.parseWhileStmt()
+		case KeywordCho: // counting loop
+			return p.parseForStmt()
+		case KeywordVoiMoi: // loop over the elements of a collection
+			return p.parseForEachStmt()
+		case KeywordDung, KeywordTiepTuc: // break, continue
+			return p.parseLoopControl()
 		case KeywordBien: // variable declartion
 			return p.parseVarDecl()
 		case KeywordTraVe: // return statement
@@ -293,18 +378,94 @@ func (p *Parser) parseStatement() (Statement, error) {
 			return nil, NewLangError(UnexpectedToken, p.current.Lexeme).At(p.current.Line, p.current.Column)
 		}
 	case TokenIdent:
+		if p.peekToken().Type == TokenColon {
+			return p.parseLabeledLoop()
+		}
 		return p.parseRegExpr()
 	default:
 		return nil, NewLangError(UnexpectedToken, p.current.Lexeme).At(p.current.Line, p.current.Column)
 	}
 }
 
+// Handles "ngoài: trong khi ..." and "ngoài: cho ...", the label names the loop for "dừng"/"tiếp tục"
+func (p *Parser) parseLabeledLoop() (Statement, error) {
+	label := p.current.Lexeme
+	p.nextToken() // Consumes the label
+	p.nextToken() // Consumes ':'
+	for p.current.Type == TokenNewLine {
+		p.nextToken()
+	}
+	if p.current.Type != TokenKeyword {
+		return nil, NewLangError(WrongToken, "vòng lặp", p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	switch p.current.Lexeme {
+	case KeywordTrongKhi:
+		stmt, err := p.parseWhileStmt()
+		if err != nil {
+			return nil, err
+		}
+		stmt.(*WhileStmt).Label = label
+		return stmt, nil
+	case KeywordCho:
+		stmt, err := p.parseForStmt()
+		if err != nil {
+			return nil, err
+		}
+		stmt.(*ForStmt).Label = label
+		return stmt, nil
+	case KeywordVoiMoi:
+		stmt, err := p.parseForEachStmt()
+		if err != nil {
+			return nil, err
+		}
+		switch loop := stmt.(type) {
+		case *ForStmt:
+			loop.Label = label
+		case *ForEachStmt:
+			loop.Label = label
+		}
+		return stmt, nil
+	default:
+		return nil, NewLangError(WrongToken, "vòng lặp", p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+}
+
+// Handles "dừng", "tiếp tục" and their labeled forms "dừng ngoài", "tiếp tục ngoài"
+func (p *Parser) parseLoopControl() (Statement, error) {
+	line, col := p.current.Line, p.current.Column
+	keyword := p.current.Lexeme
+	p.nextToken() // Consumes 'dừng' or 'tiếp tục'
+	label := ""
+	if p.current.Type == TokenIdent {
+		label = p.current.Lexeme
+		p.nextToken()
+	}
+	if keyword == KeywordDung {
+		return &BreakStmt{Label: label, Line: line, Column: col}, nil
+	}
+	return &ContinueStmt{Label: label, Line: line, Column: col}, nil
+}
+
 func (p *Parser) parseRegExpr() (Statement, error) {
 	line, col := p.current.Line, p.current.Column
 	expr, err := p.parseExpression(0)
 	if err != nil {
 		return nil, err
 	}
+	// Reassignment, for example: "a := a + 1" or "m[i] := 3"
+	if p.current.Type == TokenOperator && p.current.Lexeme == SymbolAssign {
+		p.nextToken() // Consumes ':='
+		value, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &AssignStmt{
+			Target: expr,
+			Value:  value,
+			Line:   line,
+			Column: col,
+		}, nil
+	}
 	return &RegExpr{
 		Expr:   expr,
 		Line:   line,
@@ -312,47 +473,62 @@ func (p *Parser) parseRegExpr() (Statement, error) {
 	}, nil
 }
 
-// TODO: Add multiple variable declaration in one line (its one per line for simplicity for now...)
+// Handles "biến a E Z32", "biến a, b E Z32" and "biến x, y E Z64 := 1, 2"
 func (p *Parser) parseVarDecl() (Statement, error) {
 	line, col := p.current.Line, p.current.Column
 	// Consume 'biến'
 	p.nextToken()
 
-	varName, varType, err := p.parseVarIdent()
+	vars, err := p.parseVarIdents()
 	if err != nil {
 		return nil, err
 	}
+	values := []Expression{}
 	// Optional: assignment
-	// For example: ":= expression"
+	// For example: ":= expression" or ":= expression, expression"
 	if p.current.Type == TokenOperator && p.current.Lexeme == SymbolAssign {
 		p.nextToken()
-		switch varType.(type) {
-		case *PrimitiveType, *ContainerType:
-			expr, err := p.parseExpression(0)
-			if err != nil {
-				return nil, err
+		switch vars[0].Type.(type) {
+		case *PrimitiveType, *ContainerType, *StructType:
+			for {
+				expr, err := p.parseExpression(0)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, expr)
+				if p.current.Type != TokenComma {
+					break
+				}
+				p.nextToken() // Consumes ','
+			}
+			if len(values) != len(vars) {
+				return nil, NewLangError(InitializerCountMismatch, len(values), len(vars)).At(line, col)
 			}
-			return &VarDecl{
-				Var:    &Variable{Name: varName, Type: varType, Line: line, Column: col},
-				Value:  expr,
-				Line:   line,
-				Column: col,
-			}, nil
-		case *StructType:
-			// TODO: Implement it
-			panic("Chưa cài đặt kiểu dữ liệu có cấu trúc")
 		default:
 			panic("Không nhận dạng được kiểu dữ liệu")
 		}
 	}
 
-	// No initializer found
-	return &VarDecl{
-		Var:    &Variable{Name: varName, Type: varType, Line: line, Column: col},
-		Value:  &UninitializedExpr{Line: p.current.Line, Column: p.current.Column},
-		Line:   line,
-		Column: col,
-	}, nil
+	decls := []*VarDecl{}
+	for i, v := range vars {
+		var value Expression
+		if len(values) > 0 {
+			value = values[i]
+		} else {
+			// No initializer found
+			value = &UninitializedExpr{Line: p.current.Line, Column: p.current.Column}
+		}
+		decls = append(decls, &VarDecl{
+			Var:    v,
+			Value:  value,
+			Line:   line,
+			Column: col,
+		})
+	}
+	if len(decls) == 1 {
+		return decls[0], nil
+	}
+	return &VarDeclList{Decls: decls, Line: line, Column: col}, nil
 }
 
 func (p *Parser) parseArray() (*ArrayLiteral, error) {
@@ -380,24 +556,40 @@ func (p *Parser) parseArray() (*ArrayLiteral, error) {
 	return &ArrayLiteral{Elements: elements, Type: &UnknownType{Name: "Unknown"}, Line: line, Column: col}, nil
 }
 
-func (p *Parser) parseVarIdent() (string, Type, error) {
-	// Checks for identifier
-	if p.current.Type != TokenIdent {
-		return "", nil, NewLangError(WrongToken, "tên biến", p.current.Lexeme).At(p.current.Line, p.current.Column)
+// Parses one or more names sharing a type, for example "a, b E Z32"
+func (p *Parser) parseVarIdents() ([]*Variable, error) {
+	vars := []*Variable{}
+	for {
+		// Checks for identifier
+		if p.current.Type != TokenIdent {
+			return nil, NewLangError(WrongToken, "tên biến", p.current.Lexeme).At(p.current.Line, p.current.Column)
+		}
+		vars = append(vars, &Variable{Name: p.current.Lexeme, Line: p.current.Line, Column: p.current.Column})
+		p.nextToken()
+		if p.current.Type != TokenComma {
+			break
+		}
+		p.nextToken() // Consumes the ','
 	}
-	varName := p.current.Lexeme
-	p.nextToken()
 
 	if p.current.Type != TokenOperator || p.current.Lexeme != SymbolMember {
-		return "", nil, NewLangError(WrongToken, SymbolMember, p.current.Lexeme).At(p.current.Line, p.current.Column)
+		return nil, NewLangError(WrongToken, SymbolMember, p.current.Lexeme).At(p.current.Line, p.current.Column)
 	}
 	p.nextToken() // Consumes the 'E'
 
 	varType, err := p.parseType()
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+	for i, v := range vars {
+		// Each variable gets its own copy since the type checker may fill in bounds
+		if i == 0 {
+			v.Type = varType
+		} else {
+			v.Type = copyType(varType)
+		}
 	}
-	return varName, varType, nil
+	return vars, nil
 }
 
 func (p *Parser) parseType() (Type, error) {
@@ -413,11 +605,15 @@ func (p *Parser) parseType() (Type, error) {
 	case TokenContainer:
 		containerKind := p.current.Lexeme
 		p.nextToken()
+		// Maps have no bounds, only the types of their keys and values
+		if containerKind == ContainerHashMap {
+			return p.parseHashMapType()
+		}
 
 		// Get dimension from container type
 		var dimension int
 		switch containerKind {
-		case ContainerArray, ContainerHashMap:
+		case ContainerArray:
 			dimension = 1
 		case ContainerMatrix:
 			dimension = 2
@@ -439,10 +635,8 @@ func (p *Parser) parseType() (Type, error) {
 				if err != nil {
 					return nil, err
 				}
-				// Handle left bound type
-				typeInterface := getExprType(leftBound)
-				typ, ok := typeInterface.(*PrimitiveType)
-				if (ok && typ.Name == PrimitiveR32 || typ.Name == PrimitiveR64) || !ok {
+				// Bounds may be variables, whose types are only known to the type checker
+				if typ, ok := getExprType(leftBound).(*PrimitiveType); ok && (typ.Name == PrimitiveR32 || typ.Name == PrimitiveR64) {
 					return nil, NewLangError(ExpectToken, "số tự nhiên hoặc số nguyên").At(p.current.Line, p.current.Column)
 				}
 				// Optional size declaration instead of bounds
@@ -461,9 +655,7 @@ func (p *Parser) parseType() (Type, error) {
 					return nil, err
 				}
 				// Handle right bound type
-				typeInterface = getExprType(rightBound)
-				typ, ok = typeInterface.(*PrimitiveType)
-				if (ok && typ.Name == PrimitiveR32 || typ.Name == PrimitiveR64) || !ok {
+				if typ, ok := getExprType(rightBound).(*PrimitiveType); ok && (typ.Name == PrimitiveR32 || typ.Name == PrimitiveR64) {
 					return nil, NewLangError(ExpectToken, "số tự nhiên hoặc số nguyên").At(p.current.Line, p.current.Column)
 				}
 				// Next range
@@ -501,6 +693,27 @@ func (p *Parser) parseType() (Type, error) {
 	}
 }
 
+// bảng_băm E K -> V, a map from keys of type K to values of type V
+func (p *Parser) parseHashMapType() (Type, error) {
+	if p.current.Type != TokenOperator || p.current.Lexeme != SymbolMember {
+		return nil, NewLangError(WrongToken, SymbolMember, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes the 'E'
+	keyType, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenOperator || p.current.Lexeme != SymbolArrow {
+		return nil, NewLangError(WrongToken, SymbolArrow, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes the '->'
+	valueType, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerType{Kind: ContainerHashMap, KeyType: keyType, ElementType: valueType, Dimensions: 1}, nil
+}
+
 func (p *Parser) parseReturnStmt() (Statement, error) {
 	line, column := p.current.Line, p.current.Column
 	// consume 'trả về'
@@ -528,20 +741,9 @@ func (p *Parser) parseIfStmt() (Statement, error) {
 		return nil, NewLangError(WrongToken, TokenKeyword, p.current.Lexeme).At(p.current.Line, p.current.Column)
 	}
 	p.nextToken() // Consumes the 'thì'
-	thenBlock := []Statement{}
-	for p.current.Type != TokenKeyword && (p.current.Lexeme != KeywordKetThuc && p.current.Lexeme != KeywordKhongThi) && p.current.Type != TokenEOF {
-		stmt, err := p.parseStatement()
-		if err != nil {
-			return nil, err
-		}
-		thenBlock = append(thenBlock, stmt)
-		if p.current.Type != TokenNewLine && p.current.Type != TokenSemiColon {
-			return nil, NewLangError(ExpectToken, "xuống dòng hoặc ';'").At(p.current.Line, p.current.Column)
-		}
-		p.nextToken()
-		for p.current.Type == TokenNewLine {
-			p.nextToken()
-		}
+	thenBlock, err := p.parseBlock(KeywordKetThuc, KeywordKhongThi)
+	if err != nil {
+		return nil, err
 	}
 	switch p.current.Lexeme {
 	case KeywordKetThuc:
@@ -574,20 +776,9 @@ func (p *Parser) parseIfStmt() (Statement, error) {
 				return nil, NewLangError(ExpectToken, "xuống dòng").At(p.current.Line, p.current.Column)
 			}
 			p.nextToken() // Consumes '\n'
-			elseBlock := []Statement{}
-			for p.current.Lexeme != KeywordKetThuc && p.current.Type != TokenEOF {
-				stmt, err := p.parseStatement()
-				if err != nil {
-					return nil, err
-				}
-				elseBlock = append(elseBlock, stmt)
-				if p.current.Type != TokenNewLine && p.current.Type != TokenSemiColon {
-					return nil, NewLangError(ExpectToken, "xuống dòng hoặc ';'").At(p.current.Line, p.current.Column)
-				}
-				p.nextToken()
-				for p.current.Type == TokenNewLine {
-					p.nextToken()
-				}
+			elseBlock, err := p.parseBlock(KeywordKetThuc)
+			if err != nil {
+				return nil, err
 			}
 			if p.current.Lexeme != KeywordKetThuc {
 				return nil, NewLangError(WrongToken, KeywordKetThuc, p.current.Lexeme).At(p.current.Line, p.current.Column)
@@ -606,6 +797,185 @@ func (p *Parser) parseIfStmt() (Statement, error) {
 	}
 }
 
+func (p *Parser) parseWhileStmt() (Statement, error) {
+	line, column := p.current.Line, p.current.Column
+	// Consumes 'trong khi'
+	p.nextToken()
+	condi, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordThi {
+		return nil, NewLangError(WrongToken, KeywordThi, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes the 'thì'
+	body, err := p.parseBlock(KeywordKetThuc)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordKetThuc {
+		return nil, NewLangError(WrongToken, KeywordKetThuc, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'kết thúc'
+	return &WhileStmt{
+		Condition: condi,
+		Body:      body,
+		Line:      line,
+		Column:    column,
+	}, nil
+}
+
+// Handles "cho i từ 1 đến n", "cho i từ 1..n" and "cho i từ n xuống 1", with an optional "bước" step
+func (p *Parser) parseForStmt() (Statement, error) {
+	line, column := p.current.Line, p.current.Column
+	// Consumes 'cho'
+	p.nextToken()
+	if p.current.Type != TokenIdent {
+		return nil, NewLangError(WrongToken, "tên biến", p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	loopVar := &Variable{Name: p.current.Lexeme, Type: &UnknownType{Name: "Unknown"}, Line: p.current.Line, Column: p.current.Column}
+	p.nextToken()
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordTu {
+		return nil, NewLangError(WrongToken, KeywordTu, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'từ'
+	start, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	down := false
+	switch {
+	case p.current.Type == TokenKeyword && p.current.Lexeme == KeywordDen:
+	case p.current.Type == TokenOperator && p.current.Lexeme == SymbolDotDot:
+	case p.current.Type == TokenKeyword && p.current.Lexeme == KeywordXuong:
+		down = true
+	default:
+		return nil, NewLangError(WrongToken, KeywordDen, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'đến', '..' or 'xuống'
+	end, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var step Expression
+	if p.current.Type == TokenKeyword && p.current.Lexeme == KeywordBuoc {
+		p.nextToken() // Consumes 'bước'
+		step, err = p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordThi {
+		return nil, NewLangError(WrongToken, KeywordThi, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes the 'thì'
+	body, err := p.parseBlock(KeywordKetThuc)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordKetThuc {
+		return nil, NewLangError(WrongToken, KeywordKetThuc, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'kết thúc'
+	return &ForStmt{
+		Var:    loopVar,
+		Start:  start,
+		End:    end,
+		Step:   step,
+		Down:   down,
+		Body:   body,
+		Line:   line,
+		Column: column,
+	}, nil
+}
+
+// Handles "với mỗi x trong a" and "với mỗi i, x trong a". "với mỗi i trong 1..n" counts like "cho i từ 1 đến n"
+func (p *Parser) parseForEachStmt() (Statement, error) {
+	line, column := p.current.Line, p.current.Column
+	// Consumes 'với mỗi'
+	p.nextToken()
+	vars := []*Variable{}
+	for {
+		if p.current.Type != TokenIdent {
+			return nil, NewLangError(WrongToken, "tên biến", p.current.Lexeme).At(p.current.Line, p.current.Column)
+		}
+		vars = append(vars, &Variable{Name: p.current.Lexeme, Type: &UnknownType{Name: "Unknown"}, Line: p.current.Line, Column: p.current.Column})
+		p.nextToken()
+		if len(vars) == 2 || p.current.Type != TokenComma {
+			break
+		}
+		p.nextToken() // Consumes the ','
+	}
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordTrong {
+		return nil, NewLangError(WrongToken, KeywordTrong, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'trong'
+	collection, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var end Expression
+	if p.current.Type == TokenOperator && p.current.Lexeme == SymbolDotDot {
+		if len(vars) == 2 {
+			return nil, NewLangError(ForEachRangeIndex).At(vars[0].Line, vars[0].Column)
+		}
+		p.nextToken() // Consumes '..'
+		end, err = p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordThi {
+		return nil, NewLangError(WrongToken, KeywordThi, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes the 'thì'
+	body, err := p.parseBlock(KeywordKetThuc)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.Type != TokenKeyword || p.current.Lexeme != KeywordKetThuc {
+		return nil, NewLangError(WrongToken, KeywordKetThuc, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	}
+	p.nextToken() // Consumes 'kết thúc'
+
+	if end != nil {
+		return &ForStmt{Var: vars[0], Start: collection, End: end, Body: body, Line: line, Column: column}, nil
+	}
+	loop := &ForEachStmt{Var: vars[len(vars)-1], Collection: collection, Body: body, Line: line, Column: column}
+	if len(vars) == 2 {
+		loop.Index = vars[0]
+	}
+	return loop, nil
+}
+
+// Parses statements until one of the given keywords (which is not consumed)
+func (p *Parser) parseBlock(ends ...string) ([]Statement, error) {
+	block := []Statement{}
+	for p.current.Type == TokenNewLine {
+		p.nextToken()
+	}
+	for !(p.current.Type == TokenKeyword && slices.Contains(ends, p.current.Lexeme)) && p.current.Type != TokenEOF {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		block = append(block, stmt)
+		if p.current.Type != TokenNewLine && p.current.Type != TokenSemiColon {
+			return nil, NewLangError(ExpectToken, "xuống dòng hoặc ';'").At(p.current.Line, p.current.Column)
+		}
+		p.nextToken()
+		for p.current.Type == TokenNewLine {
+			p.nextToken()
+		}
+	}
+	return block, nil
+}
+
 func (p *Parser) parseCallExpr() (Expression, error) {
 	line, column := p.current.Line, p.current.Column
 	fnName := p.current.Lexeme
@@ -647,6 +1017,19 @@ func (p *Parser) parseIndexSuffix(collection Expression) (Expression, error) {
 		}
 		indices = append(indices, index)
 
+		// Slices have a single range instead of indices
+		if len(indices) == 1 && p.current.Type == TokenOperator && p.current.Lexeme == SymbolDotDot {
+			p.nextToken() // Consumes '..'
+			end, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			if p.current.Lexeme != "]" {
+				return nil, NewLangError(WrongToken, "]", p.current.Lexeme).At(p.current.Line, p.current.Column)
+			}
+			p.nextToken() // Consumes "]"
+			return &IndexExpr{Collection: collection, Indices: indices, SliceEnd: end, Line: line, Column: column}, nil
+		}
 		if p.current.Lexeme == "]" {
 			break
 		}
@@ -659,6 +1042,64 @@ func (p *Parser) parseIndexSuffix(collection Expression) (Expression, error) {
 	return &IndexExpr{Collection: collection, Indices: indices, Line: line, Column: column}, nil
 }
 
+func (p *Parser) parseFieldSuffix(object Expression) (Expression, error) {
+	line, column := p.current.Line, p.current.Column
+	p.nextToken() // Consumes "."
+	if p.current.Type != TokenIdent {
+		return nil, NewLangError(ExpectToken, "tên trường").At(p.current.Line, p.current.Column)
+	}
+	field := p.current.Lexeme
+	p.nextToken() // Consumes the field name
+	return &FieldExpr{Object: object, Field: field, Type: &UnknownType{Name: "Unknown"}, Line: line, Column: column}, nil
+}
+
+// Handles struct literals like "Điểm{x: 1.0, y: 2.0}"
+func (p *Parser) parseStructLiteral() (Expression, error) {
+	line, column := p.current.Line, p.current.Column
+	name := p.current.Lexeme
+	p.nextToken() // Consumes name
+	p.nextToken() // Consumes '{'
+	fields := map[string]Expression{}
+	for {
+		for p.current.Type == TokenNewLine {
+			p.nextToken()
+		}
+		if p.current.Type == TokenRBrace {
+			break
+		}
+		if p.current.Type != TokenIdent {
+			return nil, NewLangError(ExpectToken, "tên trường").At(p.current.Line, p.current.Column)
+		}
+		fieldName := p.current.Lexeme
+		fieldLine, fieldCol := p.current.Line, p.current.Column
+		if _, exists := fields[fieldName]; exists {
+			return nil, NewLangError(RedeclarationField, fieldName).At(fieldLine, fieldCol)
+		}
+		p.nextToken() // Consumes field name
+		if p.current.Type != TokenColon {
+			return nil, NewLangError(WrongToken, ":", p.current.Lexeme).At(p.current.Line, p.current.Column)
+		}
+		p.nextToken() // Consumes ':'
+		expr, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		fields[fieldName] = expr
+		for p.current.Type == TokenNewLine {
+			p.nextToken()
+		}
+		if p.current.Type == TokenRBrace {
+			break
+		}
+		if p.current.Type != TokenComma {
+			return nil, NewLangError(WrongToken, "}", p.current.Lexeme).At(p.current.Line, p.current.Column)
+		}
+		p.nextToken() // Consumes ','
+	}
+	p.nextToken() // Consumes '}'
+	return &StructLiteral{StructName: name, Fields: fields, Type: &UnknownType{Name: "Unknown"}, Line: line, Column: column}, nil
+}
+
 func (p *Parser) parseExplicitCast() (Expression, error) {
 	line, column := p.current.Line, p.current.Column
 	castType := p.current.Lexeme // Primitive type
@@ -685,18 +1126,13 @@ func (p *Parser) parseExpression(minPrec int) (Expression, error) {
 	if err != nil {
 		return nil, err
 	}
+	left, err = p.parsePostfix(left)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse binary operators according to precedence
 	for {
-		switch p.current.Type {
-		case TokenLBrack: // array[index]
-			left, err = p.parseIndexSuffix(left)
-			if err != nil {
-				return nil, err
-			}
-			continue
-		}
-
 		// Binary operators
 		prec := p.currentPrecedence()
 		if prec < minPrec {
@@ -707,8 +1143,13 @@ func (p *Parser) parseExpression(minPrec int) (Expression, error) {
 		line, col := p.current.Line, p.current.Column
 		p.nextToken() // consume operator
 
-		// Parse right-hand side expression with higher precedence for right-associativity
-		right, err := p.parseExpression(prec + 1)
+		// Parse right-hand side expression with higher precedence for left-associativity
+		// Power is right-associative: 2^3^2 = 2^(3^2)
+		nextPrec := prec + 1
+		if op == SymbolCaret {
+			nextPrec = prec
+		}
+		right, err := p.parseExpression(nextPrec)
 		if err != nil {
 			return nil, err
 		}
@@ -726,8 +1167,83 @@ func (p *Parser) parseExpression(minPrec int) (Expression, error) {
 	return left, nil
 }
 
+// Handles indexing and field access following an expression
+func (p *Parser) parsePostfix(left Expression) (Expression, error) {
+	var err error
+	for {
+		switch p.current.Type {
+		case TokenLBrack: // array[index]
+			left, err = p.parseIndexSuffix(left)
+			if err != nil {
+				return nil, err
+			}
+		case TokenDot: // struct.field
+			left, err = p.parseFieldSuffix(left)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return left, nil
+		}
+	}
+}
+
+// Handles "-x", "!x" and "không x"
+func (p *Parser) parseUnaryExpr() (Expression, error) {
+	op := p.current.Lexeme
+	line, column := p.current.Line, p.current.Column
+	p.nextToken() // Consumes the operator
+
+	var operand Expression
+	var err error
+	if op == KeywordKhong {
+		// Like the written word, 'không' negates the whole comparison after it
+		operand, err = p.parseExpression(precedences[KeywordVa] + 1)
+		if err != nil {
+			return nil, err
+		}
+		op = SymbolBang
+	} else {
+		// Powers bind tighter, so -x^2 is -(x^2) like in mathematics
+		operand, err = p.parseExpression(precedences[SymbolCaret])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Fold negative number literals so they still count as literals
+	if num, ok := operand.(*NumberLiteral); ok && op == SymbolMinus {
+		if strings.HasPrefix(num.Value, "-") {
+			num.Value = num.Value[1:]
+		} else if strings.Trim(num.Value, "0") == "" {
+			// -0 is still 0, which N32 and N64 can hold
+			num.Value = "0"
+		} else {
+			num.Value = "-" + num.Value
+		}
+		num.Line, num.Column = line, column
+		return num, nil
+	}
+	return &UnaryExpr{Operator: op, Operand: operand, ReturnType: PrimitiveType{Name: "Unknown"}, Line: line, Column: column}, nil
+}
+
 func (p *Parser) parsePrimary() (Expression, error) {
 	switch p.current.Type {
+	case TokenOperator:
+		if p.current.Lexeme == SymbolMinus || p.current.Lexeme == SymbolBang {
+			return p.parseUnaryExpr()
+		}
+		return nil, NewLangError(UnexpectedToken, p.current.Lexeme).At(p.current.Line, p.current.Column)
+	case TokenKeyword:
+		if p.current.Lexeme == KeywordKhong {
+			return p.parseUnaryExpr()
+		}
+		if p.current.Lexeme == KeywordDdung || p.current.Lexeme == KeywordSai {
+			b := &BooleanLiteral{Value: p.current.Lexeme == KeywordDdung, Type: PrimitiveType{Name: PrimitiveB1}, Line: p.current.Line, Column: p.current.Column}
+			p.nextToken()
+			return b, nil
+		}
+		return nil, NewLangError(UnexpectedToken, p.current.Lexeme).At(p.current.Line, p.current.Column)
 	case TokenPrimitive:
 		casted, err := p.parseExplicitCast()
 		if err != nil {
@@ -741,6 +1257,12 @@ func (p *Parser) parsePrimary() (Expression, error) {
 				return nil, err
 			}
 			return call, nil
+		} else if p.peekToken().Type == TokenLBrace {
+			lit, err := p.parseStructLiteral()
+			if err != nil {
+				return nil, err
+			}
+			return lit, nil
 		} else {
 			id := &Identifier{Name: p.current.Lexeme, Type: &UnknownType{Name: "Unknown"}, Line: p.current.Line, Column: p.current.Column}
 			p.nextToken()
@@ -755,6 +1277,14 @@ func (p *Parser) parsePrimary() (Expression, error) {
 		}
 		p.nextToken()
 		return num, nil
+	case TokenString:
+		str := &StringLiteral{Value: p.current.Lexeme, Type: PrimitiveType{Name: PrimitiveS8}, Line: p.current.Line, Column: p.current.Column}
+		p.nextToken()
+		return str, nil
+	case TokenChar:
+		char := &CharLiteral{Value: []rune(p.current.Lexeme)[0], Type: PrimitiveType{Name: PrimitiveC32}, Line: p.current.Line, Column: p.current.Column}
+		p.nextToken()
+		return char, nil
 	case TokenLParen:
 		p.nextToken() // Consumes '('
 		expr, err := p.parseExpression(0)
@@ -856,6 +1386,61 @@ func isTypeNumber_Type(typ Type) bool {
 	}
 }
 
+func isTypeInteger_Type(typ Type) bool {
+	switch typ := typ.(type) {
+	case *PrimitiveType:
+		switch typ.Name {
+		case PrimitiveN32, PrimitiveN64, PrimitiveZ32, PrimitiveZ64:
+			return true
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+}
+
+func isTypeChar_Type(typ Type) bool {
+	switch typ := typ.(type) {
+	case *PrimitiveType:
+		return typ.Name == PrimitiveC8 || typ.Name == PrimitiveC16 || typ.Name == PrimitiveC32
+	default:
+		return false
+	}
+}
+
+func isTypeUnsigned_Type(typ Type) bool {
+	switch typ := typ.(type) {
+	case *PrimitiveType:
+		return typ.Name == PrimitiveN32 || typ.Name == PrimitiveN64
+	default:
+		return false
+	}
+}
+
+// Helper function
+func copyType(typ Type) Type {
+	switch t := typ.(type) {
+	case *PrimitiveType:
+		return &PrimitiveType{Name: t.Name}
+	case *StructType:
+		return &StructType{Name: t.Name, Fields: t.Fields, Order: t.Order}
+	case *ContainerType:
+		return &ContainerType{
+			Kind:           t.Kind,
+			KeyType:        t.KeyType,
+			ElementType:    copyType(t.ElementType),
+			Dimensions:     t.Dimensions,
+			Bounds:         append([]Expression{}, t.Bounds...),
+			IsDynamic:      t.IsDynamic,
+			IsRuntimeSized: t.IsRuntimeSized,
+			IsOpen:         t.IsOpen,
+		}
+	default:
+		return typ
+	}
+}
+
 // Helper function
 func getExprType(expr Expression) Type {
 	switch e := expr.(type) {
@@ -863,7 +1448,18 @@ func getExprType(expr Expression) Type {
 		return e.Type
 	case *NumberLiteral:
 		return &e.Type
+	case *StringLiteral:
+		return &e.Type
+	case *BooleanLiteral:
+		return &e.Type
+	case *CharLiteral:
+		return &e.Type
 	case *BinaryExpr:
+		if e.MatrixType != nil {
+			return e.MatrixType
+		}
+		return &e.ReturnType
+	case *UnaryExpr:
 		return &e.ReturnType
 	case *CallExpr:
 		return e.ReturnType
@@ -871,6 +1467,18 @@ func getExprType(expr Expression) Type {
 		return &e.Type
 	case *ArrayLiteral:
 		return e.Type
+	case *StructLiteral:
+		return e.Type
+	case *FieldExpr:
+		return e.Type
+	case *IndexExpr:
+		if e.SliceType != nil {
+			return e.SliceType
+		}
+		if containerType, ok := getExprType(e.Collection).(*ContainerType); ok {
+			return containerType.ElementType
+		}
+		return &UnknownType{Name: "Unknown"}
 	default:
 		return &UnknownType{Name: "Unknown"}
 	}
@@ -0,0 +1,850 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Runtime helpers written directly in LLVM IR, generated on first use.
+// Their names contain a '.' so they can never clash with user functions.
+
+// Moves codegen into the entry block of fn, so a helper's body can use the same
+// loops as user code. Calling the returned function goes back to the caller
+func (ctx *CodegenContext) enterFunc(fn *ir.Func) func() {
+	callerFunc, callerBlock := ctx.Func, ctx.Block
+	ctx.Func = fn
+	ctx.Block = fn.NewBlock("entry")
+	return func() { ctx.Func, ctx.Block = callerFunc, callerBlock }
+}
+
+// Integer power by squaring: banh.pow.i64(base, exp)
+func (ctx *CodegenContext) intPowFunc(typ *types.IntType, unsigned bool) *ir.Func {
+	prefix := "i"
+	if unsigned {
+		prefix = "u"
+	}
+	name := fmt.Sprintf("banh.pow.%s%d", prefix, typ.BitSize)
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	base := ir.NewParam("base", typ)
+	exp := ir.NewParam("exp", typ)
+	fn := ctx.Module.NewFunc(name, typ, base, exp)
+	fn.Linkage = enum.LinkagePrivate
+	zero := constant.NewInt(typ, 0)
+	one := constant.NewInt(typ, 1)
+
+	entry := fn.NewBlock("entry")
+	negExp := fn.NewBlock("neg")
+	loop := fn.NewBlock("loop")
+	body := fn.NewBlock("body")
+	end := fn.NewBlock("end")
+
+	if unsigned {
+		entry.NewBr(loop)
+	} else {
+		entry.NewCondBr(entry.NewICmp(enum.IPredSLT, exp, zero), negExp, loop)
+	}
+
+	// A negative exponent truncates 1/base^n, only 1 and -1 don't become 0
+	minusOne := constant.NewInt(typ, -1)
+	isOne := negExp.NewICmp(enum.IPredEQ, base, one)
+	isMinusOne := negExp.NewICmp(enum.IPredEQ, base, minusOne)
+	isOdd := negExp.NewICmp(enum.IPredNE, negExp.NewAnd(exp, one), zero)
+	minusOneRes := negExp.NewSelect(isOdd, minusOne, one)
+	res := negExp.NewSelect(isMinusOne, minusOneRes, zero)
+	negExp.NewRet(negExp.NewSelect(isOne, one, res))
+
+	// Square and multiply
+	accPhi := loop.NewPhi(ir.NewIncoming(one, entry))
+	basePhi := loop.NewPhi(ir.NewIncoming(base, entry))
+	expPhi := loop.NewPhi(ir.NewIncoming(exp, entry))
+	loop.NewCondBr(loop.NewICmp(enum.IPredEQ, expPhi, zero), end, body)
+
+	bitSet := body.NewICmp(enum.IPredNE, body.NewAnd(expPhi, one), zero)
+	nextAcc := body.NewSelect(bitSet, body.NewMul(accPhi, basePhi), accPhi)
+	nextBase := body.NewMul(basePhi, basePhi)
+	nextExp := body.NewLShr(expPhi, one)
+	body.NewBr(loop)
+	accPhi.Incs = append(accPhi.Incs, ir.NewIncoming(nextAcc, body))
+	basePhi.Incs = append(basePhi.Incs, ir.NewIncoming(nextBase, body))
+	expPhi.Incs = append(expPhi.Incs, ir.NewIncoming(nextExp, body))
+
+	end.NewRet(accPhi)
+	return fn
+}
+
+// Floating point power through the llvm.pow intrinsic
+func (ctx *CodegenContext) floatPowFunc(typ *types.FloatType) *ir.Func {
+	name := "llvm.pow.f64"
+	if typ.Kind == types.FloatKindFloat {
+		name = "llvm.pow.f32"
+	}
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+	return ctx.Module.NewFunc(name, typ, ir.NewParam("", typ), ir.NewParam("", typ))
+}
+
+// Prints a code point encoded as UTF-8: banh.print.char(cp)
+func (ctx *CodegenContext) printCharFunc() *ir.Func {
+	name := "banh.print.char"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	cp := ir.NewParam("cp", types.I32)
+	fn := ctx.Module.NewFunc(name, types.I32, cp)
+	fn.Linkage = enum.LinkagePrivate
+	i8 := func(v int64) *constant.Int { return constant.NewInt(types.I8, v) }
+	i32 := func(v int64) *constant.Int { return constant.NewInt(types.I32, v) }
+
+	entry := fn.NewBlock("entry")
+	one := fn.NewBlock("one")
+	notOne := fn.NewBlock("not.one")
+	two := fn.NewBlock("two")
+	notTwo := fn.NewBlock("not.two")
+	three := fn.NewBlock("three")
+	four := fn.NewBlock("four")
+	output := fn.NewBlock("print")
+
+	// Room for 4 bytes and the terminator
+	buf := entry.NewAlloca(types.NewArray(5, types.I8))
+	byteAt := func(block *ir.Block, i int64) value.Value {
+		return block.NewGetElementPtr(buf, i32(0), i32(i))
+	}
+	// Continuation byte: 10xxxxxx holding 6 bits of cp starting at shift
+	cont := func(block *ir.Block, shift int64) value.Value {
+		bits := block.NewAnd(block.NewLShr(cp, i32(shift)), i32(0x3F))
+		return block.NewTrunc(block.NewOr(bits, i32(0x80)), types.I8)
+	}
+	// Leading byte: prefix followed by the bits of cp above shift
+	lead := func(block *ir.Block, prefix, shift int64) value.Value {
+		return block.NewTrunc(block.NewOr(block.NewLShr(cp, i32(shift)), i32(prefix)), types.I8)
+	}
+	entry.NewCondBr(entry.NewICmp(enum.IPredULT, cp, i32(0x80)), one, notOne)
+
+	one.NewStore(one.NewTrunc(cp, types.I8), byteAt(one, 0))
+	one.NewStore(i8(0), byteAt(one, 1))
+	one.NewBr(output)
+
+	notOne.NewCondBr(notOne.NewICmp(enum.IPredULT, cp, i32(0x800)), two, notTwo)
+
+	two.NewStore(lead(two, 0xC0, 6), byteAt(two, 0))
+	two.NewStore(cont(two, 0), byteAt(two, 1))
+	two.NewStore(i8(0), byteAt(two, 2))
+	two.NewBr(output)
+
+	notTwo.NewCondBr(notTwo.NewICmp(enum.IPredULT, cp, i32(0x10000)), three, four)
+
+	three.NewStore(lead(three, 0xE0, 12), byteAt(three, 0))
+	three.NewStore(cont(three, 6), byteAt(three, 1))
+	three.NewStore(cont(three, 0), byteAt(three, 2))
+	three.NewStore(i8(0), byteAt(three, 3))
+	three.NewBr(output)
+
+	four.NewStore(lead(four, 0xF0, 18), byteAt(four, 0))
+	four.NewStore(cont(four, 12), byteAt(four, 1))
+	four.NewStore(cont(four, 6), byteAt(four, 2))
+	four.NewStore(cont(four, 0), byteAt(four, 3))
+	four.NewStore(i8(0), byteAt(four, 4))
+	four.NewBr(output)
+
+	strPtr := byteAt(output, 0)
+	output.NewRet(output.NewCall(findFunction(ctx.Module, "printf"), ctx.constString("%s"), strPtr))
+	return fn
+}
+
+// Makes room for one more element in a dynamic array: banh.array.reserve(arr, elemSize).
+// Every dynamic array has the layout of { i8*, i64, i64 }, so one helper serves them all
+func (ctx *CodegenContext) arrayReserveFunc() *ir.Func {
+	name := "banh.array.reserve"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	arr := ir.NewParam("arr", types.NewPointer(dynamicArrayType(types.I8)))
+	size := ir.NewParam("size", types.I64)
+	fn := ctx.Module.NewFunc(name, types.Void, arr, size)
+	fn.Linkage = enum.LinkagePrivate
+	i32 := func(v int64) *constant.Int { return constant.NewInt(types.I32, v) }
+	i64 := func(v int64) *constant.Int { return constant.NewInt(types.I64, v) }
+
+	entry := fn.NewBlock("entry")
+	grow := fn.NewBlock("grow")
+	copyData := fn.NewBlock("copy")
+	update := fn.NewBlock("update")
+	done := fn.NewBlock("done")
+
+	dataPtr := entry.NewGetElementPtr(arr, i32(0), i32(0))
+	lenPtr := entry.NewGetElementPtr(arr, i32(0), i32(1))
+	capPtr := entry.NewGetElementPtr(arr, i32(0), i32(2))
+	data := entry.NewLoad(dataPtr)
+	length := entry.NewLoad(lenPtr)
+	capacity := entry.NewLoad(capPtr)
+	entry.NewCondBr(entry.NewICmp(enum.IPredUGE, length, capacity), grow, done)
+
+	// Double the capacity, starting at 4
+	small := grow.NewICmp(enum.IPredULT, length, i64(4))
+	newCap := grow.NewSelect(small, i64(4), grow.NewMul(length, i64(2)))
+	// Static storage can't be passed to realloc, it's copied into a fresh block instead
+	owned := grow.NewICmp(enum.IPredNE, capacity, i64(0))
+	src := grow.NewSelect(owned, data, constant.NewNull(types.I8Ptr))
+	newData := grow.NewCall(findFunction(ctx.Module, "realloc"), src, grow.NewMul(newCap, size))
+	grow.NewCondBr(owned, update, copyData)
+
+	copyData.NewCall(findFunction(ctx.Module, "memcpy"), newData, data, copyData.NewMul(length, size))
+	copyData.NewBr(update)
+
+	update.NewStore(newData, dataPtr)
+	update.NewStore(newCap, capPtr)
+	update.NewBr(done)
+
+	done.NewRet(nil)
+	return fn
+}
+
+// Determinant by Gaussian elimination with partial pivoting: banh.matrix.det(data, n).
+// data holds an n x n matrix of doubles row by row and is overwritten
+func (ctx *CodegenContext) determinantFunc() *ir.Func {
+	name := "banh.matrix.det"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	data := ir.NewParam("data", types.NewPointer(types.Double))
+	n := ir.NewParam("n", types.I64)
+	fn := ctx.Module.NewFunc(name, types.Double, data, n)
+	fn.Linkage = enum.LinkagePrivate
+	f64 := func(v float64) *constant.Float { return constant.NewFloat(types.Double, v) }
+	one := constant.NewInt(types.I64, 1)
+
+	defer ctx.enterFunc(fn)()
+	a := matrixView{data: data, rows: n, cols: n}
+	load := func(r, c value.Value) value.Value { return ctx.Block.NewLoad(a.at(r, c, ctx)) }
+	fabs := ctx.fabsFunc()
+
+	det := ctx.Block.NewAlloca(types.Double)
+	pivot := ctx.Block.NewAlloca(types.I64)
+	best := ctx.Block.NewAlloca(types.Double)
+	ctx.Block.NewStore(f64(1), det)
+
+	ctx.emitRange(n, func(k value.Value) {
+		below := ctx.Block.NewAdd(k, one)
+		belowCount := ctx.Block.NewSub(n, below)
+
+		// The row with the largest value in column k becomes the pivot row
+		ctx.Block.NewStore(k, pivot)
+		ctx.Block.NewStore(ctx.Block.NewCall(fabs, load(k, k)), best)
+		ctx.emitRange(belowCount, func(offset value.Value) {
+			i := ctx.Block.NewAdd(below, offset)
+			val := ctx.Block.NewCall(fabs, load(i, k))
+			larger := ctx.Block.NewFCmp(enum.FPredOGT, val, ctx.Block.NewLoad(best))
+			ctx.Block.NewStore(ctx.Block.NewSelect(larger, i, ctx.Block.NewLoad(pivot)), pivot)
+			ctx.Block.NewStore(ctx.Block.NewSelect(larger, val, ctx.Block.NewLoad(best)), best)
+		})
+
+		// Nothing to pivot on means the matrix is singular
+		flowID := ctx.NextFlowID()
+		singular := fn.NewBlock(fmt.Sprintf("singular.%d", flowID))
+		regular := fn.NewBlock(fmt.Sprintf("regular.%d", flowID))
+		ctx.Block.NewCondBr(ctx.Block.NewFCmp(enum.FPredOEQ, ctx.Block.NewLoad(best), f64(0)), singular, regular)
+		singular.NewRet(f64(0))
+		ctx.Block = regular
+
+		// Swapping two rows flips the sign of the determinant
+		p := ctx.Block.NewLoad(pivot)
+		ctx.emitRange(n, func(j value.Value) {
+			top, other := load(k, j), load(p, j)
+			ctx.Block.NewStore(other, a.at(k, j, ctx))
+			ctx.Block.NewStore(top, a.at(p, j, ctx))
+		})
+		sign := ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredNE, p, k), f64(-1), f64(1))
+		pivotVal := load(k, k)
+		ctx.Block.NewStore(ctx.Block.NewFMul(ctx.Block.NewLoad(det), ctx.Block.NewFMul(sign, pivotVal)), det)
+
+		// Clear column k below the pivot
+		ctx.emitRange(belowCount, func(offset value.Value) {
+			i := ctx.Block.NewAdd(below, offset)
+			factor := ctx.Block.NewFDiv(load(i, k), pivotVal)
+			ctx.emitRange(ctx.Block.NewSub(n, k), func(offset value.Value) {
+				j := ctx.Block.NewAdd(k, offset)
+				reduced := ctx.Block.NewFSub(load(i, j), ctx.Block.NewFMul(factor, load(k, j)))
+				ctx.Block.NewStore(reduced, a.at(i, j, ctx))
+			})
+		})
+	})
+	ctx.Block.NewRet(ctx.Block.NewLoad(det))
+	return fn
+}
+
+// Absolute value through the llvm.fabs intrinsic
+func (ctx *CodegenContext) fabsFunc() *ir.Func {
+	name := "llvm.fabs.f64"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+	return ctx.Module.NewFunc(name, types.Double, ir.NewParam("", types.Double))
+}
+
+// Fields of a hash map, see hashMapType
+const (
+	mapKeys = iota
+	mapStates
+	mapValues
+	mapLength
+	mapUsed
+	mapCapacity
+)
+
+// States of a hash map slot
+const (
+	slotEmpty = iota
+	slotFull
+	slotRemoved
+)
+
+func (ctx *CodegenContext) mapField(m value.Value, field int64) value.Value {
+	return ctx.Block.NewGetElementPtr(m, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, field))
+}
+
+// Hash of a key: banh.map.hash(key, str). Strings use FNV-1a over their bytes
+func (ctx *CodegenContext) mapHashFunc() *ir.Func {
+	name := "banh.map.hash"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	key := ir.NewParam("key", types.I64)
+	str := ir.NewParam("str", types.I1)
+	fn := ctx.Module.NewFunc(name, types.I64, key, str)
+	fn.Linkage = enum.LinkagePrivate
+	i64 := func(v uint64) *constant.Int { return constant.NewInt(types.I64, int64(v)) }
+
+	entry := fn.NewBlock("entry")
+	number := fn.NewBlock("number")
+	text := fn.NewBlock("text")
+	loop := fn.NewBlock("loop")
+	body := fn.NewBlock("body")
+	done := fn.NewBlock("done")
+	entry.NewCondBr(str, text, number)
+
+	// Spreads nearby integers over the whole table
+	mixed := number.NewMul(key, i64(0x9E3779B97F4A7C15))
+	number.NewRet(number.NewXor(mixed, number.NewLShr(mixed, i64(32))))
+
+	ptr := text.NewIntToPtr(key, types.I8Ptr)
+	text.NewBr(loop)
+	index := loop.NewPhi(ir.NewIncoming(i64(0), text))
+	hash := loop.NewPhi(ir.NewIncoming(i64(0xCBF29CE484222325), text))
+	char := loop.NewLoad(loop.NewGetElementPtr(ptr, index))
+	loop.NewCondBr(loop.NewICmp(enum.IPredEQ, char, constant.NewInt(types.I8, 0)), done, body)
+
+	nextHash := body.NewMul(body.NewXor(hash, body.NewZExt(char, types.I64)), i64(0x100000001B3))
+	nextIndex := body.NewAdd(index, i64(1))
+	body.NewBr(loop)
+	index.Incs = append(index.Incs, ir.NewIncoming(nextIndex, body))
+	hash.Incs = append(hash.Incs, ir.NewIncoming(nextHash, body))
+
+	done.NewRet(hash)
+	return fn
+}
+
+// Compares two keys: banh.map.equal(a, b, str). Empty slots hold 0, which is never compared as a string
+func (ctx *CodegenContext) mapEqualFunc() *ir.Func {
+	name := "banh.map.equal"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	a := ir.NewParam("a", types.I64)
+	b := ir.NewParam("b", types.I64)
+	str := ir.NewParam("str", types.I1)
+	fn := ctx.Module.NewFunc(name, types.I1, a, b, str)
+	fn.Linkage = enum.LinkagePrivate
+	zero := constant.NewInt(types.I64, 0)
+
+	entry := fn.NewBlock("entry")
+	text := fn.NewBlock("text")
+	done := fn.NewBlock("done")
+
+	same := entry.NewICmp(enum.IPredEQ, a, b)
+	null := entry.NewOr(entry.NewICmp(enum.IPredEQ, a, zero), entry.NewICmp(enum.IPredEQ, b, zero))
+	entry.NewCondBr(entry.NewAnd(str, entry.NewXor(null, constant.True)), text, done)
+
+	cmp := text.NewCall(findFunction(ctx.Module, "strcmp"), text.NewIntToPtr(a, types.I8Ptr), text.NewIntToPtr(b, types.I8Ptr))
+	equal := text.NewICmp(enum.IPredEQ, cmp, constant.NewInt(types.I32, 0))
+	text.NewBr(done)
+
+	done.NewRet(done.NewPhi(ir.NewIncoming(same, entry), ir.NewIncoming(equal, text)))
+	return fn
+}
+
+// Emits a linear probe starting at the slot of key, stopping at the first slot
+// where stop holds. Returns the index of that slot. The map can't be empty
+func (ctx *CodegenContext) mapProbe(m, key, str value.Value, stop func(i, state value.Value) value.Value) value.Value {
+	mask := ctx.Block.NewSub(ctx.Block.NewLoad(ctx.mapField(m, mapCapacity)), constant.NewInt(types.I64, 1))
+	states := ctx.Block.NewLoad(ctx.mapField(m, mapStates))
+	first := ctx.Block.NewAnd(ctx.Block.NewCall(ctx.mapHashFunc(), key, str), mask)
+
+	loopID := ctx.NextLoopID()
+	probeBlock := ctx.Func.NewBlock(fmt.Sprintf("probe.%d", loopID))
+	nextBlock := ctx.Func.NewBlock(fmt.Sprintf("probe.next.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("probe.end.%d", loopID))
+	startBlock := ctx.Block
+	ctx.Block.NewBr(probeBlock)
+
+	ctx.Block = probeBlock
+	i := ctx.Block.NewPhi(ir.NewIncoming(first, startBlock))
+	state := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(states, i))
+	ctx.Block.NewCondBr(stop(i, state), leaveBlock, nextBlock)
+
+	next := nextBlock.NewAnd(nextBlock.NewAdd(i, constant.NewInt(types.I64, 1)), mask)
+	nextBlock.NewBr(probeBlock)
+	i.Incs = append(i.Incs, ir.NewIncoming(next, nextBlock))
+
+	ctx.Block = leaveBlock
+	return i
+}
+
+// Slot holding key, or -1: banh.map.find(map, key, str)
+func (ctx *CodegenContext) mapFindFunc() *ir.Func {
+	name := "banh.map.find"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	m := ir.NewParam("map", types.NewPointer(hashMapType()))
+	key := ir.NewParam("key", types.I64)
+	str := ir.NewParam("str", types.I1)
+	fn := ctx.Module.NewFunc(name, types.I64, m, key, str)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	full := constant.NewInt(types.I8, slotFull)
+	missing := constant.NewInt(types.I64, -1)
+
+	empty := fn.NewBlock("empty")
+	search := fn.NewBlock("search")
+	capacity := ctx.Block.NewLoad(ctx.mapField(m, mapCapacity))
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, capacity, constant.NewInt(types.I64, 0)), empty, search)
+	empty.NewRet(missing)
+
+	// Removed entries don't stop the search, the key may be further along
+	ctx.Block = search
+	keys := ctx.Block.NewLoad(ctx.mapField(m, mapKeys))
+	i := ctx.mapProbe(m, key, str, func(i, state value.Value) value.Value {
+		stored := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(keys, i))
+		isKey := ctx.Block.NewAnd(ctx.Block.NewICmp(enum.IPredEQ, state, full), ctx.Block.NewCall(ctx.mapEqualFunc(), stored, key, str))
+		return ctx.Block.NewOr(ctx.Block.NewICmp(enum.IPredEQ, state, constant.NewInt(types.I8, slotEmpty)), isKey)
+	})
+	state := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(ctx.Block.NewLoad(ctx.mapField(m, mapStates)), i))
+	ctx.Block.NewRet(ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredEQ, state, full), i, missing))
+	return fn
+}
+
+// Pointer to the value of key, adding the key when it's missing: banh.map.insert(map, key, str, size)
+func (ctx *CodegenContext) mapInsertFunc() *ir.Func {
+	name := "banh.map.insert"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	m := ir.NewParam("map", types.NewPointer(hashMapType()))
+	key := ir.NewParam("key", types.I64)
+	str := ir.NewParam("str", types.I1)
+	size := ir.NewParam("size", types.I64)
+	fn := ctx.Module.NewFunc(name, types.I8Ptr, m, key, str, size)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	one := constant.NewInt(types.I64, 1)
+	full := constant.NewInt(types.I8, slotFull)
+	valueAt := func(i value.Value) value.Value {
+		values := ctx.Block.NewLoad(ctx.mapField(m, mapValues))
+		return ctx.Block.NewGetElementPtr(values, ctx.Block.NewMul(i, size))
+	}
+
+	existing := fn.NewBlock("existing")
+	add := fn.NewBlock("add")
+	grow := fn.NewBlock("grow")
+	place := fn.NewBlock("place")
+	found := ctx.Block.NewCall(ctx.mapFindFunc(), m, key, str)
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, found, constant.NewInt(types.I64, -1)), add, existing)
+
+	ctx.Block = existing
+	ctx.Block.NewRet(valueAt(found))
+
+	// Keep at least half of the slots empty so probes stay short and always end
+	ctx.Block = add
+	used := ctx.Block.NewLoad(ctx.mapField(m, mapUsed))
+	capacity := ctx.Block.NewLoad(ctx.mapField(m, mapCapacity))
+	crowded := ctx.Block.NewICmp(enum.IPredUGT, ctx.Block.NewMul(ctx.Block.NewAdd(used, one), constant.NewInt(types.I64, 2)), capacity)
+	ctx.Block.NewCondBr(crowded, grow, place)
+
+	grow.NewCall(ctx.mapRehashFunc(), m, str, size)
+	grow.NewBr(place)
+
+	// The key isn't in the map, so the first free slot takes it
+	ctx.Block = place
+	i := ctx.mapProbe(m, key, str, func(i, state value.Value) value.Value {
+		return ctx.Block.NewICmp(enum.IPredNE, state, full)
+	})
+	statePtr := ctx.Block.NewGetElementPtr(ctx.Block.NewLoad(ctx.mapField(m, mapStates)), i)
+	wasEmpty := ctx.Block.NewICmp(enum.IPredEQ, ctx.Block.NewLoad(statePtr), constant.NewInt(types.I8, slotEmpty))
+	usedPtr := ctx.mapField(m, mapUsed)
+	ctx.Block.NewStore(ctx.Block.NewAdd(ctx.Block.NewLoad(usedPtr), ctx.Block.NewZExt(wasEmpty, types.I64)), usedPtr)
+	lengthPtr := ctx.mapField(m, mapLength)
+	ctx.Block.NewStore(ctx.Block.NewAdd(ctx.Block.NewLoad(lengthPtr), one), lengthPtr)
+	ctx.Block.NewStore(full, statePtr)
+	ctx.Block.NewStore(key, ctx.Block.NewGetElementPtr(ctx.Block.NewLoad(ctx.mapField(m, mapKeys)), i))
+	// New values start out zeroed, like uninitialized variables
+	slot := valueAt(i)
+	ctx.Block.NewCall(findFunction(ctx.Module, "memset"), slot, constant.NewInt(types.I32, 0), size)
+	ctx.Block.NewRet(slot)
+	return fn
+}
+
+// Moves every entry into slots twice as many, starting at 8: banh.map.rehash(map, str, size).
+// Removed entries are dropped on the way
+func (ctx *CodegenContext) mapRehashFunc() *ir.Func {
+	name := "banh.map.rehash"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	m := ir.NewParam("map", types.NewPointer(hashMapType()))
+	str := ir.NewParam("str", types.I1)
+	size := ir.NewParam("size", types.I64)
+	fn := ctx.Module.NewFunc(name, types.Void, m, str, size)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	i64 := func(v int64) *constant.Int { return constant.NewInt(types.I64, v) }
+	calloc := findFunction(ctx.Module, "calloc")
+	free := findFunction(ctx.Module, "free")
+
+	oldCap := ctx.Block.NewLoad(ctx.mapField(m, mapCapacity))
+	oldKeys := ctx.Block.NewLoad(ctx.mapField(m, mapKeys))
+	oldStates := ctx.Block.NewLoad(ctx.mapField(m, mapStates))
+	oldValues := ctx.Block.NewLoad(ctx.mapField(m, mapValues))
+	newCap := ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredEQ, oldCap, i64(0)), i64(8), ctx.Block.NewMul(oldCap, i64(2)))
+
+	// Zeroed keys and states make every new slot empty
+	newKeys := ctx.Block.NewBitCast(ctx.Block.NewCall(calloc, newCap, i64(8)), types.NewPointer(types.I64))
+	newStates := ctx.Block.NewCall(calloc, newCap, i64(1))
+	newValues := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), ctx.Block.NewMul(newCap, size))
+	ctx.Block.NewStore(newKeys, ctx.mapField(m, mapKeys))
+	ctx.Block.NewStore(newStates, ctx.mapField(m, mapStates))
+	ctx.Block.NewStore(newValues, ctx.mapField(m, mapValues))
+	ctx.Block.NewStore(ctx.Block.NewLoad(ctx.mapField(m, mapLength)), ctx.mapField(m, mapUsed))
+	ctx.Block.NewStore(newCap, ctx.mapField(m, mapCapacity))
+
+	ctx.emitRange(oldCap, func(j value.Value) {
+		flowID := ctx.NextFlowID()
+		move := ctx.Func.NewBlock(fmt.Sprintf("move.%d", flowID))
+		skip := ctx.Func.NewBlock(fmt.Sprintf("skip.%d", flowID))
+		state := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(oldStates, j))
+		ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, state, constant.NewInt(types.I8, slotFull)), move, skip)
+
+		ctx.Block = move
+		key := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(oldKeys, j))
+		i := ctx.mapProbe(m, key, str, func(i, state value.Value) value.Value {
+			return ctx.Block.NewICmp(enum.IPredEQ, state, constant.NewInt(types.I8, slotEmpty))
+		})
+		ctx.Block.NewStore(constant.NewInt(types.I8, slotFull), ctx.Block.NewGetElementPtr(newStates, i))
+		ctx.Block.NewStore(key, ctx.Block.NewGetElementPtr(newKeys, i))
+		dst := ctx.Block.NewGetElementPtr(newValues, ctx.Block.NewMul(i, size))
+		src := ctx.Block.NewGetElementPtr(oldValues, ctx.Block.NewMul(j, size))
+		ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), dst, src, size)
+		ctx.Block.NewBr(skip)
+
+		ctx.Block = skip
+	})
+	ctx.Block.NewCall(free, ctx.Block.NewBitCast(oldKeys, types.I8Ptr))
+	ctx.Block.NewCall(free, oldStates)
+	ctx.Block.NewCall(free, oldValues)
+	ctx.Block.NewRet(nil)
+	return fn
+}
+
+// A map with its own copy of the slots of map: banh.map.copy(map, size)
+func (ctx *CodegenContext) mapCopyFunc() *ir.Func {
+	name := "banh.map.copy"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	m := ir.NewParam("map", types.NewPointer(hashMapType()))
+	size := ir.NewParam("size", types.I64)
+	fn := ctx.Module.NewFunc(name, hashMapType(), m, size)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	malloc := findFunction(ctx.Module, "malloc")
+	memcpy := findFunction(ctx.Module, "memcpy")
+
+	capacity := ctx.Block.NewLoad(ctx.mapField(m, mapCapacity))
+	duplicate := func(field int64, bytes value.Value) value.Value {
+		old := ctx.Block.NewLoad(ctx.mapField(m, field))
+		raw := ctx.Block.NewCall(malloc, bytes)
+		ctx.Block.NewCall(memcpy, raw, ctx.Block.NewBitCast(old, types.I8Ptr), bytes)
+		return ctx.Block.NewBitCast(raw, old.Type())
+	}
+	var copied value.Value = constant.NewUndef(hashMapType())
+	copied = ctx.Block.NewInsertValue(copied, duplicate(mapKeys, ctx.Block.NewMul(capacity, constant.NewInt(types.I64, 8))), mapKeys)
+	copied = ctx.Block.NewInsertValue(copied, duplicate(mapStates, capacity), mapStates)
+	copied = ctx.Block.NewInsertValue(copied, duplicate(mapValues, ctx.Block.NewMul(capacity, size)), mapValues)
+	for _, field := range []int64{mapLength, mapUsed, mapCapacity} {
+		copied = ctx.Block.NewInsertValue(copied, ctx.Block.NewLoad(ctx.mapField(m, field)), uint64(field))
+	}
+	ctx.Block.NewRet(copied)
+	return fn
+}
+
+// Removes key, telling whether it was there: banh.map.remove(map, key, str)
+func (ctx *CodegenContext) mapRemoveFunc() *ir.Func {
+	name := "banh.map.remove"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	m := ir.NewParam("map", types.NewPointer(hashMapType()))
+	key := ir.NewParam("key", types.I64)
+	str := ir.NewParam("str", types.I1)
+	fn := ctx.Module.NewFunc(name, types.I1, m, key, str)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+
+	missing := fn.NewBlock("missing")
+	remove := fn.NewBlock("remove")
+	i := ctx.Block.NewCall(ctx.mapFindFunc(), m, key, str)
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredEQ, i, constant.NewInt(types.I64, -1)), missing, remove)
+	missing.NewRet(constant.False)
+
+	// The slot keeps probes for other keys going, so it's marked instead of emptied
+	ctx.Block = remove
+	states := ctx.Block.NewLoad(ctx.mapField(m, mapStates))
+	ctx.Block.NewStore(constant.NewInt(types.I8, slotRemoved), ctx.Block.NewGetElementPtr(states, i))
+	lengthPtr := ctx.mapField(m, mapLength)
+	ctx.Block.NewStore(ctx.Block.NewSub(ctx.Block.NewLoad(lengthPtr), constant.NewInt(types.I64, 1)), lengthPtr)
+	ctx.Block.NewRet(constant.True)
+	return fn
+}
+
+// Name suffix for the helpers ordering elements of typ, like the llvm type
+// with signedness: i32, u64, f64. Characters are unsigned, strings are "str"
+func orderKey(typ Type, llvmType types.Type) string {
+	switch t := llvmType.(type) {
+	case *types.IntType:
+		if isTypeUnsigned_Type(typ) || isTypeChar_Type(typ) {
+			return fmt.Sprintf("u%d", t.BitSize)
+		}
+		return fmt.Sprintf("i%d", t.BitSize)
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return "f32"
+		}
+		return "f64"
+	}
+	return "str"
+}
+
+// Emits a < b, strings are compared by strcmp
+func (ctx *CodegenContext) lessThan(a, b value.Value, typ Type) value.Value {
+	switch {
+	case canFCmp(a, b):
+		return ctx.Block.NewFCmp(enum.FPredOLT, a, b)
+	case canStrCmp(a, b):
+		cmp := ctx.Block.NewCall(findFunction(ctx.Module, "strcmp"), a, b)
+		return ctx.Block.NewICmp(enum.IPredSLT, cmp, constant.NewInt(types.I32, 0))
+	}
+	unsigned := isTypeUnsigned_Type(typ) || isTypeChar_Type(typ)
+	return ctx.Block.NewICmp(intPred(enum.IPredSLT, enum.IPredULT, unsigned), a, b)
+}
+
+// Emits a loop running body for as long as cond holds
+func (ctx *CodegenContext) emitWhile(cond func() value.Value, body func()) {
+	loopID := ctx.NextLoopID()
+	condBlock := ctx.Func.NewBlock(fmt.Sprintf("while.cond.%d", loopID))
+	bodyBlock := ctx.Func.NewBlock(fmt.Sprintf("while.body.%d", loopID))
+	leaveBlock := ctx.Func.NewBlock(fmt.Sprintf("while.end.%d", loopID))
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = condBlock
+	ctx.Block.NewCondBr(cond(), bodyBlock, leaveBlock)
+
+	ctx.Block = bodyBlock
+	body()
+	ctx.Block.NewBr(condBlock)
+
+	ctx.Block = leaveBlock
+}
+
+// Stable bottom-up merge sort: banh.array.sort.i32(data, n, desc).
+// Runs twice as long are merged through a scratch buffer until one run is left
+func (ctx *CodegenContext) sortFunc(elemType Type, ptrType types.Type) *ir.Func {
+	name := "banh.array.sort." + orderKey(elemType, ptrType.(*types.PointerType).ElemType)
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	data := ir.NewParam("data", ptrType)
+	n := ir.NewParam("n", types.I64)
+	desc := ir.NewParam("desc", types.I1)
+	fn := ctx.Module.NewFunc(name, types.Void, data, n, desc)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	i64 := func(v int64) *constant.Int { return constant.NewInt(types.I64, v) }
+	min := func(a, b value.Value) value.Value {
+		return ctx.Block.NewSelect(ctx.Block.NewICmp(enum.IPredSLT, a, b), a, b)
+	}
+	at := func(ptr, i value.Value) value.Value { return ctx.Block.NewGetElementPtr(ptr, i) }
+
+	size := ctx.Block.NewMul(n, sizeOf(ptrType.(*types.PointerType).ElemType))
+	raw := ctx.Block.NewCall(findFunction(ctx.Module, "malloc"), size)
+	scratch := ctx.Block.NewBitCast(raw, ptrType)
+	width := ctx.Block.NewAlloca(types.I64)
+	left := ctx.Block.NewAlloca(types.I64)
+	right := ctx.Block.NewAlloca(types.I64)
+	ctx.Block.NewStore(i64(1), width)
+
+	ctx.emitWhile(func() value.Value {
+		return ctx.Block.NewICmp(enum.IPredSLT, ctx.Block.NewLoad(width), n)
+	}, func() {
+		w := ctx.Block.NewLoad(width)
+		pair := ctx.Block.NewMul(w, i64(2))
+		runs := ctx.Block.NewSDiv(ctx.Block.NewAdd(n, ctx.Block.NewSub(pair, i64(1))), pair)
+		ctx.emitRange(runs, func(r value.Value) {
+			lo := ctx.Block.NewMul(r, pair)
+			mid := min(ctx.Block.NewAdd(lo, w), n)
+			hi := min(ctx.Block.NewAdd(lo, pair), n)
+			ctx.Block.NewStore(lo, left)
+			ctx.Block.NewStore(mid, right)
+			ctx.emitRange(ctx.Block.NewSub(hi, lo), func(offset value.Value) {
+				i, j := ctx.Block.NewLoad(left), ctx.Block.NewLoad(right)
+				leftDone := ctx.Block.NewICmp(enum.IPredSGE, i, mid)
+				rightDone := ctx.Block.NewICmp(enum.IPredSGE, j, hi)
+				// Finished runs are read at lo instead, which is always there, and then ignored
+				a := ctx.Block.NewLoad(at(data, ctx.Block.NewSelect(leftDone, lo, i)))
+				b := ctx.Block.NewLoad(at(data, ctx.Block.NewSelect(rightDone, lo, j)))
+				// Ties take the left element, which keeps the sort stable
+				before := ctx.Block.NewSelect(desc, ctx.lessThan(a, b, elemType), ctx.lessThan(b, a, elemType))
+				takeRight := ctx.Block.NewAnd(ctx.Block.NewXor(rightDone, constant.True), ctx.Block.NewOr(leftDone, before))
+				ctx.Block.NewStore(ctx.Block.NewSelect(takeRight, b, a), at(scratch, ctx.Block.NewAdd(lo, offset)))
+				ctx.Block.NewStore(ctx.Block.NewAdd(j, ctx.Block.NewZExt(takeRight, types.I64)), right)
+				ctx.Block.NewStore(ctx.Block.NewAdd(i, ctx.Block.NewZExt(ctx.Block.NewXor(takeRight, constant.True), types.I64)), left)
+			})
+		})
+		ctx.Block.NewCall(findFunction(ctx.Module, "memcpy"), ctx.Block.NewBitCast(data, types.I8Ptr), raw, size)
+		ctx.Block.NewStore(pair, width)
+	})
+	ctx.Block.NewCall(findFunction(ctx.Module, "free"), raw)
+	ctx.Block.NewRet(nil)
+	return fn
+}
+
+// Binary search in an ascending array: banh.array.search.i32(data, n, x).
+// Gives the first index holding x, or -1
+func (ctx *CodegenContext) searchFunc(elemType Type, ptrType types.Type) *ir.Func {
+	name := "banh.array.search." + orderKey(elemType, ptrType.(*types.PointerType).ElemType)
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	data := ir.NewParam("data", ptrType)
+	n := ir.NewParam("n", types.I64)
+	x := ir.NewParam("x", ptrType.(*types.PointerType).ElemType)
+	fn := ctx.Module.NewFunc(name, types.I64, data, n, x)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	i64 := func(v int64) *constant.Int { return constant.NewInt(types.I64, v) }
+
+	// Narrow [lo, hi) down to the first element that isn't less than x
+	lo := ctx.Block.NewAlloca(types.I64)
+	hi := ctx.Block.NewAlloca(types.I64)
+	ctx.Block.NewStore(i64(0), lo)
+	ctx.Block.NewStore(n, hi)
+	ctx.emitWhile(func() value.Value {
+		return ctx.Block.NewICmp(enum.IPredSLT, ctx.Block.NewLoad(lo), ctx.Block.NewLoad(hi))
+	}, func() {
+		low, high := ctx.Block.NewLoad(lo), ctx.Block.NewLoad(hi)
+		mid := ctx.Block.NewAdd(low, ctx.Block.NewSDiv(ctx.Block.NewSub(high, low), i64(2)))
+		less := ctx.lessThan(ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, mid)), x, elemType)
+		ctx.Block.NewStore(ctx.Block.NewSelect(less, ctx.Block.NewAdd(mid, i64(1)), low), lo)
+		ctx.Block.NewStore(ctx.Block.NewSelect(less, high, mid), hi)
+	})
+
+	// That element is x unless x is bigger than it
+	check := fn.NewBlock("check")
+	missing := fn.NewBlock("missing")
+	found := ctx.Block.NewLoad(lo)
+	ctx.Block.NewCondBr(ctx.Block.NewICmp(enum.IPredSLT, found, n), check, missing)
+	missing.NewRet(i64(-1))
+
+	ctx.Block = check
+	bigger := ctx.lessThan(x, ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, found)), elemType)
+	ctx.Block.NewRet(ctx.Block.NewSelect(bigger, i64(-1), found))
+	return fn
+}
+
+// Smallest or largest element of a non-empty array: banh.array.extreme.i32(data, n, largest).
+// The first one wins among equal elements
+func (ctx *CodegenContext) extremeFunc(elemType Type, ptrType types.Type) *ir.Func {
+	name := "banh.array.extreme." + orderKey(elemType, ptrType.(*types.PointerType).ElemType)
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	data := ir.NewParam("data", ptrType)
+	n := ir.NewParam("n", types.I64)
+	largest := ir.NewParam("largest", types.I1)
+	fn := ctx.Module.NewFunc(name, ptrType.(*types.PointerType).ElemType, data, n, largest)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+
+	best := ctx.Block.NewAlloca(ptrType.(*types.PointerType).ElemType)
+	ctx.Block.NewStore(ctx.Block.NewLoad(data), best)
+	ctx.emitRange(n, func(i value.Value) {
+		val := ctx.Block.NewLoad(ctx.Block.NewGetElementPtr(data, i))
+		current := ctx.Block.NewLoad(best)
+		better := ctx.Block.NewSelect(largest, ctx.lessThan(current, val, elemType), ctx.lessThan(val, current, elemType))
+		ctx.Block.NewStore(ctx.Block.NewSelect(better, val, current), best)
+	})
+	ctx.Block.NewRet(ctx.Block.NewLoad(best))
+	return fn
+}
+
+// Reverses n elements of size bytes each in place: banh.array.reverse(data, n, size).
+// Elements are swapped byte by byte, so any element type works
+func (ctx *CodegenContext) reverseFunc() *ir.Func {
+	name := "banh.array.reverse"
+	if fn := findFunction(ctx.Module, name); fn != nil {
+		return fn
+	}
+
+	data := ir.NewParam("data", types.I8Ptr)
+	n := ir.NewParam("n", types.I64)
+	size := ir.NewParam("size", types.I64)
+	fn := ctx.Module.NewFunc(name, types.Void, data, n, size)
+	fn.Linkage = enum.LinkagePrivate
+	defer ctx.enterFunc(fn)()
+	one := constant.NewInt(types.I64, 1)
+
+	half := ctx.Block.NewSDiv(n, constant.NewInt(types.I64, 2))
+	ctx.emitRange(half, func(i value.Value) {
+		front := ctx.Block.NewGetElementPtr(data, ctx.Block.NewMul(i, size))
+		back := ctx.Block.NewGetElementPtr(data, ctx.Block.NewMul(ctx.Block.NewSub(ctx.Block.NewSub(n, one), i), size))
+		ctx.emitRange(size, func(b value.Value) {
+			frontByte := ctx.Block.NewGetElementPtr(front, b)
+			backByte := ctx.Block.NewGetElementPtr(back, b)
+			first, last := ctx.Block.NewLoad(frontByte), ctx.Block.NewLoad(backByte)
+			ctx.Block.NewStore(last, frontByte)
+			ctx.Block.NewStore(first, backByte)
+		})
+	})
+	ctx.Block.NewRet(nil)
+	return fn
+}
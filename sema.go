@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // TODO: Handle default values
@@ -63,6 +67,8 @@ func (s *Scope) Resolve(name string) (any, bool) {
 type TypeChecker struct {
 	GlobalScope  *Scope
 	CurrentScope *Scope
+	Structs      map[string]*StructType
+	loopLabels   []string // Enclosing loops, innermost last, "" when unlabeled
 }
 
 // Entry point
@@ -73,9 +79,41 @@ func (tc *TypeChecker) AnalyzeProgram(p *Program) error {
 		return err
 	}
 
+	// Declare struct names first so fields and signatures can refer to any of them
+	tc.Structs = make(map[string]*StructType)
+	for _, decl := range p.Structs {
+		if _, exists := tc.Structs[decl.Name]; exists {
+			return NewLangError(RedeclarationStruct, decl.Name).At(decl.Line, decl.Column)
+		}
+		tc.Structs[decl.Name] = &StructType{Name: decl.Name, Fields: make(map[string]Type)}
+	}
+	for _, decl := range p.Structs {
+		err := tc.AnalyzeStructDecl(decl)
+		if err != nil {
+			return err
+		}
+	}
+	for _, decl := range p.Structs {
+		if structContains(tc.Structs[decl.Name], decl.Name, map[string]bool{}) {
+			return NewLangError(RecursiveStruct, decl.Name).At(decl.Line, decl.Column)
+		}
+	}
+
 	// First, declare all functions (for forward reference)
 	for _, fn := range p.Functions {
-		err := tc.GlobalScope.Declare(fn.Name, &Function{
+		for _, param := range fn.Parameters {
+			typ, err := tc.resolveFixedType(param.Type, param.Line, param.Column)
+			if err != nil {
+				return err
+			}
+			param.Type = openParamType(typ)
+		}
+		returnType, err := tc.resolveFixedType(fn.ReturnType, fn.Line, fn.Column)
+		if err != nil {
+			return err
+		}
+		fn.ReturnType = returnType
+		err = tc.GlobalScope.Declare(fn.Name, &Function{
 			Name:       fn.Name,
 			Parameters: fn.Parameters,
 			ReturnType: fn.ReturnType,
@@ -87,6 +125,28 @@ func (tc *TypeChecker) AnalyzeProgram(p *Program) error {
 		}
 	}
 
+	// Declare global variables, their initializers must be known at compile time
+	tc.CurrentScope = tc.GlobalScope
+	for _, global := range p.Globals {
+		if !isConstantExpr(global.Value) {
+			line, col := global.Value.Pos()
+			return NewLangError(NonConstantGlobal, global.Var.Name).At(line, col)
+		}
+		typ, err := tc.resolveFixedType(global.Var.Type, global.Var.Line, global.Var.Column)
+		if err != nil {
+			return err
+		}
+		global.Var.Type = typ
+		err = tc.AnalyzeType(&global.Var.Type, &global.Value)
+		if err != nil {
+			return err
+		}
+		err = tc.GlobalScope.Declare(global.Var.Name, global.Var)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Then check function bodies
 	for _, fn := range p.Functions {
 		err := tc.AnalyzeFunction(fn)
@@ -97,6 +157,138 @@ func (tc *TypeChecker) AnalyzeProgram(p *Program) error {
 	return nil
 }
 
+func (tc *TypeChecker) AnalyzeStructDecl(decl *StructDecl) error {
+	st := tc.Structs[decl.Name]
+	for _, field := range decl.Fields {
+		if _, exists := st.Fields[field.Name]; exists {
+			return NewLangError(RedeclarationField, field.Name).At(field.Line, field.Column)
+		}
+		typ, err := tc.resolveFixedType(field.Type, field.Line, field.Column)
+		if err != nil {
+			return err
+		}
+		field.Type = typ
+		st.Fields[field.Name] = typ
+		st.Order = append(st.Order, field.Name)
+	}
+	return nil
+}
+
+// Replaces struct names written in the source with their declarations
+func (tc *TypeChecker) resolveType(typ Type, line, col int) (Type, error) {
+	switch t := typ.(type) {
+	case *StructType:
+		st, ok := tc.Structs[t.Name]
+		if !ok {
+			return nil, NewLangError(UnknownStructType, t.Name).At(line, col)
+		}
+		return st, nil
+	case *ContainerType:
+		elemType, err := tc.resolveType(t.ElementType, line, col)
+		if err != nil {
+			return nil, err
+		}
+		t.ElementType = elemType
+		if t.Kind == ContainerHashMap && !isMapKeyType(t.KeyType) {
+			return nil, NewLangError(InvalidMapKey, t.KeyType).At(line, col)
+		}
+		// Matrices can't grow, their shape is part of the type
+		if t.Kind == ContainerMatrix && t.IsDynamic {
+			return nil, NewLangError(DynamicMatrix, t).At(line, col)
+		}
+		// Only the outermost container can be sized at runtime, elements all have the same size
+		if elemContainer, ok := elemType.(*ContainerType); ok && elemContainer.IsRuntimeSized {
+			return nil, NewLangError(RuntimeBoundNotAllowed, elemContainer).At(line, col)
+		}
+		foldBounds(t)
+		return t, nil
+	default:
+		return typ, nil
+	}
+}
+
+// Like resolveType, for places where the size has to be known while compiling
+func (tc *TypeChecker) resolveFixedType(typ Type, line, col int) (Type, error) {
+	resolved, err := tc.resolveType(typ, line, col)
+	if err != nil {
+		return nil, err
+	}
+	if container, ok := resolved.(*ContainerType); ok && container.IsRuntimeSized {
+		return nil, NewLangError(RuntimeBoundNotAllowed, container).At(line, col)
+	}
+	return resolved, nil
+}
+
+// Parameters written mảng[] E T take any array of T by reference, with the bounds it was declared with
+func openParamType(typ Type) Type {
+	container, ok := typ.(*ContainerType)
+	if !ok || container.Kind != ContainerArray || !container.IsDynamic {
+		return typ
+	}
+	return &ContainerType{Kind: ContainerArray, ElementType: container.ElementType, Dimensions: 1, IsRuntimeSized: true, IsOpen: true}
+}
+
+// Replaces bounds like 2*3 or -1 with their value. Anything else is evaluated when the program runs
+func foldBounds(typ *ContainerType) {
+	for i, bound := range typ.Bounds {
+		val, ok := foldIntConstant(bound)
+		if !ok {
+			typ.IsRuntimeSized = true
+			continue
+		}
+		line, col := bound.Pos()
+		typ.Bounds[i] = &NumberLiteral{Value: strconv.FormatInt(val, 10), Type: PrimitiveType{Name: PrimitiveZ64}, Line: line, Column: col}
+	}
+}
+
+// Value of an integer expression made only of literals
+func foldIntConstant(expr Expression) (int64, bool) {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		if !isTypeInteger_Type(&e.Type) {
+			return 0, false
+		}
+		val, err := strconv.ParseInt(e.Value, 10, 64)
+		return val, err == nil
+	case *UnaryExpr:
+		val, ok := foldIntConstant(e.Operand)
+		return -val, ok && e.Operator == SymbolMinus
+	case *BinaryExpr:
+		left, ok1 := foldIntConstant(e.Left)
+		right, ok2 := foldIntConstant(e.Right)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch e.Operator {
+		case SymbolPlus:
+			return left + right, true
+		case SymbolMinus:
+			return left - right, true
+		case SymbolAsterisk:
+			return left * right, true
+		}
+	}
+	return 0, false
+}
+
+// Bounds left after folding are checked like any other expression
+func (tc *TypeChecker) AnalyzeBounds(typ *ContainerType) error {
+	for _, bound := range typ.Bounds {
+		if _, ok := bound.(*NumberLiteral); ok {
+			continue
+		}
+		err := tc.AnalyzeExpression(bound)
+		if err != nil {
+			return err
+		}
+		if boundType := tc.getExprType(bound); !isTypeInteger_Type(boundType) {
+			line, col := bound.Pos()
+			return NewLangError(InvalidArrayBound, boundType).At(line, col)
+		}
+	}
+	return nil
+}
+
 func (tc *TypeChecker) InitializeBuiltins() error {
 	// Define the print function signature: in(tuỳ) -> rỗng
 	printFn := &Function{
@@ -111,6 +303,170 @@ func (tc *TypeChecker) InitializeBuiltins() error {
 		return err
 	}
 
+	// thêm(mảng, phần tử) appends to a dynamic array
+	appendFn := &Function{
+		Name: "thêm",
+		Parameters: []*Variable{
+			{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "phần_tử", Type: &PrimitiveType{Name: PrimitiveAny}},
+		},
+		ReturnType: &PrimitiveType{Name: PrimitiveVoid},
+	}
+	err = tc.GlobalScope.Declare("thêm", appendFn)
+	if err != nil {
+		return err
+	}
+
+	// độ_dài(mảng) -> Z64
+	lengthFn := &Function{
+		Name:       "độ_dài",
+		Parameters: []*Variable{{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveZ64},
+	}
+	err = tc.GlobalScope.Declare("độ_dài", lengthFn)
+	if err != nil {
+		return err
+	}
+
+	// sàn(mảng) and trần(mảng) -> Z64, the lower and upper bounds
+	for _, name := range []string{"sàn", "trần"} {
+		boundFn := &Function{
+			Name:       name,
+			Parameters: []*Variable{{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}}},
+			ReturnType: &PrimitiveType{Name: PrimitiveZ64},
+		}
+		err = tc.GlobalScope.Declare(name, boundFn)
+		if err != nil {
+			return err
+		}
+	}
+
+	// chuyển_vị(ma_trận) -> ma_trận, the shape is swapped
+	transposeFn := &Function{
+		Name:       "chuyển_vị",
+		Parameters: []*Variable{{Name: "ma_trận", Type: &PrimitiveType{Name: PrimitiveAny}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveAny},
+	}
+	err = tc.GlobalScope.Declare("chuyển_vị", transposeFn)
+	if err != nil {
+		return err
+	}
+
+	// đơn_vị(n) -> ma_trận[0..n-1, 0..n-1] E R64
+	identityFn := &Function{
+		Name:       "đơn_vị",
+		Parameters: []*Variable{{Name: "n", Type: &PrimitiveType{Name: PrimitiveZ64}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveAny},
+	}
+	err = tc.GlobalScope.Declare("đơn_vị", identityFn)
+	if err != nil {
+		return err
+	}
+
+	// định_thức(ma_trận) -> R64
+	determinantFn := &Function{
+		Name:       "định_thức",
+		Parameters: []*Variable{{Name: "ma_trận", Type: &PrimitiveType{Name: PrimitiveAny}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveR64},
+	}
+	err = tc.GlobalScope.Declare("định_thức", determinantFn)
+	if err != nil {
+		return err
+	}
+
+	// tìm(bảng, khoá, giá_trị) -> B1, giá_trị is set when the key is found
+	findFn := &Function{
+		Name: "tìm",
+		Parameters: []*Variable{
+			{Name: "bảng", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "khoá", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "giá_trị", Type: &PrimitiveType{Name: PrimitiveAny}},
+		},
+		ReturnType: &PrimitiveType{Name: PrimitiveB1},
+	}
+	err = tc.GlobalScope.Declare("tìm", findFn)
+	if err != nil {
+		return err
+	}
+
+	// xoá(bảng, khoá) -> B1, whether the key was there
+	removeFn := &Function{
+		Name: "xoá",
+		Parameters: []*Variable{
+			{Name: "bảng", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "khoá", Type: &PrimitiveType{Name: PrimitiveAny}},
+		},
+		ReturnType: &PrimitiveType{Name: PrimitiveB1},
+	}
+	err = tc.GlobalScope.Declare("xoá", removeFn)
+	if err != nil {
+		return err
+	}
+
+	// các_khoá(bảng) -> mảng[] E khoá, to go through a map
+	keysFn := &Function{
+		Name:       "các_khoá",
+		Parameters: []*Variable{{Name: "bảng", Type: &PrimitiveType{Name: PrimitiveAny}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveAny},
+	}
+	err = tc.GlobalScope.Declare("các_khoá", keysFn)
+	if err != nil {
+		return err
+	}
+
+	// sắp_xếp(mảng, giảm_dần) sorts in place and keeps equal elements in order.
+	// giảm_dần can be left out, see AnalyzeCallExpr
+	sortFn := &Function{
+		Name: "sắp_xếp",
+		Parameters: []*Variable{
+			{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "giảm_dần", Type: &PrimitiveType{Name: PrimitiveB1}},
+		},
+		ReturnType: &PrimitiveType{Name: PrimitiveVoid},
+	}
+	err = tc.GlobalScope.Declare("sắp_xếp", sortFn)
+	if err != nil {
+		return err
+	}
+
+	// tìm_nhị_phân(mảng, x) -> Z64, the index of x in a sorted array or sàn(mảng) - 1
+	searchFn := &Function{
+		Name: "tìm_nhị_phân",
+		Parameters: []*Variable{
+			{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}},
+			{Name: "x", Type: &PrimitiveType{Name: PrimitiveAny}},
+		},
+		ReturnType: &PrimitiveType{Name: PrimitiveZ64},
+	}
+	err = tc.GlobalScope.Declare("tìm_nhị_phân", searchFn)
+	if err != nil {
+		return err
+	}
+
+	// nhỏ_nhất(mảng) and lớn_nhất(mảng) -> phần tử
+	for _, name := range []string{"nhỏ_nhất", "lớn_nhất"} {
+		extremeFn := &Function{
+			Name:       name,
+			Parameters: []*Variable{{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}}},
+			ReturnType: &PrimitiveType{Name: PrimitiveAny},
+		}
+		err = tc.GlobalScope.Declare(name, extremeFn)
+		if err != nil {
+			return err
+		}
+	}
+
+	// đảo_ngược(mảng) reverses in place
+	reverseFn := &Function{
+		Name:       "đảo_ngược",
+		Parameters: []*Variable{{Name: "mảng", Type: &PrimitiveType{Name: PrimitiveAny}}},
+		ReturnType: &PrimitiveType{Name: PrimitiveVoid},
+	}
+	err = tc.GlobalScope.Declare("đảo_ngược", reverseFn)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Add more later
 	return nil
 }
@@ -139,7 +495,18 @@ func (tc *TypeChecker) AnalyzeFunction(fn *Function) error {
 func (tc *TypeChecker) AnalyzeStatement(stmt Statement, expectedReturnType Type) error {
 	switch s := stmt.(type) {
 	case *VarDecl:
-		err := tc.AnalyzeType(&s.Var.Type, &s.Value)
+		typ, err := tc.resolveType(s.Var.Type, s.Var.Line, s.Var.Column)
+		if err != nil {
+			return err
+		}
+		s.Var.Type = typ
+		if container, ok := typ.(*ContainerType); ok && container.IsRuntimeSized {
+			err = tc.AnalyzeBounds(container)
+			if err != nil {
+				return err
+			}
+		}
+		err = tc.AnalyzeAssignedValue(s.Var.Type, &s.Value)
 		if err != nil {
 			return err
 		}
@@ -149,11 +516,21 @@ func (tc *TypeChecker) AnalyzeStatement(stmt Statement, expectedReturnType Type)
 			return err
 		}
 		return nil
+	case *VarDeclList:
+		for _, decl := range s.Decls {
+			err := tc.AnalyzeStatement(decl, expectedReturnType)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	case *ReturnStmt:
-		err := tc.AnalyzeType(&expectedReturnType, &s.Value)
+		// Returning is assigning to the caller, arrays of another kind are copied
+		err := tc.AnalyzeAssignedValue(expectedReturnType, &s.Value)
 		if err != nil {
 			return err
 		}
+		s.Type = expectedReturnType
 		return nil
 	case *IfStmt:
 		err := tc.AnalyzeExpression(s.Condition)
@@ -175,6 +552,67 @@ func (tc *TypeChecker) AnalyzeStatement(stmt Statement, expectedReturnType Type)
 			}
 		}
 		return nil
+	case *WhileStmt:
+		err := tc.AnalyzeExpression(s.Condition)
+		if err != nil {
+			return err
+		}
+		condType := tc.getExprType(s.Condition)
+		if !isSameTypeAndName(condType, &PrimitiveType{Name: PrimitiveB1}) {
+			line, col := s.Condition.Pos()
+			return NewLangError(TypeMismatch, condType.String(), PrimitiveB1).At(line, col)
+		}
+		err = tc.enterLoop(s.Label, s.Line, s.Column)
+		if err != nil {
+			return err
+		}
+		defer tc.leaveLoop()
+
+		// Variables declared in the body only live inside the loop
+		outer := tc.CurrentScope
+		tc.CurrentScope = NewScope(outer)
+		defer func() { tc.CurrentScope = outer }()
+		for _, stmt := range s.Body {
+			err := tc.AnalyzeStatement(stmt, expectedReturnType)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ForStmt:
+		err := tc.AnalyzeForStmt(s, expectedReturnType)
+		if err != nil {
+			return err
+		}
+		return nil
+	case *ForEachStmt:
+		return tc.AnalyzeForEachStmt(s, expectedReturnType)
+	case *BreakStmt:
+		return tc.checkLoopControl(KeywordDung, s.Label, s.Line, s.Column)
+	case *ContinueStmt:
+		return tc.checkLoopControl(KeywordTiepTuc, s.Label, s.Line, s.Column)
+	case *AssignStmt:
+		switch target := s.Target.(type) {
+		case *Identifier, *FieldExpr:
+		case *IndexExpr:
+			if target.SliceEnd != nil {
+				line, col := s.Target.Pos()
+				return NewLangError(InvalidAssignTarget).At(line, col)
+			}
+		default:
+			line, col := s.Target.Pos()
+			return NewLangError(InvalidAssignTarget).At(line, col)
+		}
+		err := tc.AnalyzeExpression(s.Target)
+		if err != nil {
+			return err
+		}
+		targetType := tc.getExprType(s.Target)
+		err = tc.AnalyzeAssignedValue(targetType, &s.Value)
+		if err != nil {
+			return err
+		}
+		return nil
 	case *RegExpr:
 		err := tc.AnalyzeExpression(s.Expr)
 		if err != nil {
@@ -186,6 +624,193 @@ func (tc *TypeChecker) AnalyzeStatement(stmt Statement, expectedReturnType Type)
 	}
 }
 
+func (tc *TypeChecker) AnalyzeForStmt(f *ForStmt, expectedReturnType Type) error {
+	err := tc.AnalyzeExpression(f.Start)
+	if err != nil {
+		return err
+	}
+	err = tc.AnalyzeExpression(f.End)
+	if err != nil {
+		return err
+	}
+	startType := tc.getExprType(f.Start)
+	endType := tc.getExprType(f.End)
+	if !isTypeInteger_Type(startType) {
+		line, col := f.Start.Pos()
+		return NewLangError(InvalidLoopBound, startType.String()).At(line, col)
+	}
+	if !isTypeInteger_Type(endType) {
+		line, col := f.End.Pos()
+		return NewLangError(InvalidLoopBound, endType.String()).At(line, col)
+	}
+
+	// The loop variable takes the type of the bounds, literals adapt to the other bound
+	var varType Type
+	switch {
+	case isSameTypeAndName(startType, endType):
+		varType = copyType(startType)
+	case isLiteral(f.Start) && !isLiteral(f.End) && canLiteralCast(startType, endType):
+		varType = copyType(endType)
+	case !isLiteral(f.Start) && isLiteral(f.End) && canLiteralCast(endType, startType):
+		varType = copyType(startType)
+	case canImplicitCast(startType, endType):
+		varType = copyType(endType)
+	case canImplicitCast(endType, startType):
+		varType = copyType(startType)
+	default:
+		line, col := f.End.Pos()
+		return NewLangError(TypeMismatch, endType.String(), startType.String()).At(line, col)
+	}
+	err = tc.AnalyzeType(&varType, &f.Start)
+	if err != nil {
+		return err
+	}
+	err = tc.AnalyzeType(&varType, &f.End)
+	if err != nil {
+		return err
+	}
+	if f.Step != nil {
+		err = tc.AnalyzeType(&varType, &f.Step)
+		if err != nil {
+			return err
+		}
+		// Direction comes from 'đến'/'xuống', so the step must be positive
+		if num, ok := f.Step.(*NumberLiteral); ok {
+			if val, err := strconv.ParseInt(num.Value, 10, 64); err == nil && val <= 0 {
+				line, col := num.Pos()
+				return NewLangError(InvalidLoopStep).At(line, col)
+			}
+		}
+	}
+	f.Var.Type = varType
+	err = tc.enterLoop(f.Label, f.Line, f.Column)
+	if err != nil {
+		return err
+	}
+	defer tc.leaveLoop()
+
+	// The loop variable only lives inside the loop
+	outer := tc.CurrentScope
+	tc.CurrentScope = NewScope(outer)
+	defer func() { tc.CurrentScope = outer }()
+	err = tc.CurrentScope.Declare(f.Var.Name, f.Var)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range f.Body {
+		err := tc.AnalyzeStatement(stmt, expectedReturnType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tc *TypeChecker) AnalyzeForEachStmt(f *ForEachStmt, expectedReturnType Type) error {
+	err := tc.AnalyzeExpression(f.Collection)
+	if err != nil {
+		return err
+	}
+	collecType := tc.getExprType(f.Collection)
+	container, ok := collecType.(*ContainerType)
+	if !ok || container.Kind == ContainerHashMap {
+		line, col := f.Collection.Pos()
+		return NewLangError(ForEachNotIterable, collecType).At(line, col)
+	}
+	// Rows of a matrix are open arrays into its storage
+	f.Var.Type = copyType(container.ElementType)
+	if container.Kind == ContainerMatrix {
+		f.Var.Type = &ContainerType{Kind: ContainerArray, ElementType: container.ElementType, Dimensions: 1, IsRuntimeSized: true, IsOpen: true}
+	}
+	if f.Index != nil {
+		f.Index.Type = &PrimitiveType{Name: PrimitiveZ64}
+	}
+
+	err = tc.enterLoop(f.Label, f.Line, f.Column)
+	if err != nil {
+		return err
+	}
+	defer tc.leaveLoop()
+
+	// The loop variables only live inside the loop
+	outer := tc.CurrentScope
+	tc.CurrentScope = NewScope(outer)
+	defer func() { tc.CurrentScope = outer }()
+	for _, v := range []*Variable{f.Index, f.Var} {
+		if v == nil {
+			continue
+		}
+		err = tc.CurrentScope.Declare(v.Name, v)
+		if err != nil {
+			return err
+		}
+	}
+	for _, stmt := range f.Body {
+		err := tc.AnalyzeStatement(stmt, expectedReturnType)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tc *TypeChecker) enterLoop(label string, line, col int) error {
+	if label != "" && slices.Contains(tc.loopLabels, label) {
+		return NewLangError(RedeclarationLoopLabel, label).At(line, col)
+	}
+	tc.loopLabels = append(tc.loopLabels, label)
+	return nil
+}
+
+func (tc *TypeChecker) leaveLoop() {
+	tc.loopLabels = tc.loopLabels[:len(tc.loopLabels)-1]
+}
+
+// "dừng" and "tiếp tục" need an enclosing loop, and the label (if any) must name one
+func (tc *TypeChecker) checkLoopControl(keyword string, label string, line, col int) error {
+	if len(tc.loopLabels) == 0 {
+		return NewLangError(LoopControlOutsideLoop, keyword).At(line, col)
+	}
+	if label != "" && !slices.Contains(tc.loopLabels, label) {
+		return NewLangError(UnknownLoopLabel, label).At(line, col)
+	}
+	return nil
+}
+
+// Assigned arrays and matrices are copied element by element, so any array or matrix with the
+// same element type fits. Sizes not known while compiling are compared when the program runs
+func (tc *TypeChecker) AnalyzeAssignedValue(target Type, value *Expression) error {
+	targetArr, ok := target.(*ContainerType)
+	if _, isLit := (*value).(*ArrayLiteral); !ok || isLit || targetArr.Kind == ContainerHashMap || targetArr.IsOpen {
+		return tc.AnalyzeType(&target, value)
+	}
+	if _, ok := (*value).(*UninitializedExpr); ok {
+		return nil
+	}
+	err := tc.AnalyzeExpression(*value)
+	if err != nil {
+		return err
+	}
+	valueArr, ok := tc.getExprType(*value).(*ContainerType)
+	if !ok || valueArr.Kind != targetArr.Kind || !isSameTypeAndName(valueArr.ElementType, targetArr.ElementType) {
+		// Reports the mismatch
+		return tc.AnalyzeType(&target, value)
+	}
+	targetShape, ok1 := literalShape(targetArr)
+	valueShape, ok2 := literalShape(valueArr)
+	if ok1 && ok2 && !slices.Equal(targetShape, valueShape) {
+		line, col := (*value).Pos()
+		if targetArr.Kind == ContainerMatrix {
+			return NewLangError(MatrixShapeMismatch, valueShape[0], valueShape[1], targetShape[0], targetShape[1]).At(line, col)
+		}
+		return NewLangError(
+			TypeMismatch,
+			fmt.Sprintf("%s (%d phần tử)", valueArr.String(), valueShape[0]),
+			fmt.Sprintf("%s (%d phần tử)", targetArr.String(), targetShape[0])).At(line, col)
+	}
+	return nil
+}
+
 func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 	err := tc.AnalyzeExpression(*checked)
 	if err != nil {
@@ -194,6 +819,10 @@ func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 	if (*checker).String() == PrimitiveAny {
 		return nil
 	}
+	// Uninitialized variables are zeroed, so any type is fine
+	if _, ok := (*checked).(*UninitializedExpr); ok {
+		return nil
+	}
 	// Priority: first check if its a container
 	checkedType := tc.getExprType(*checked)
 
@@ -202,6 +831,34 @@ func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 
 	// If both are containers
 	if ok1 && ok2 {
+		// An open array can only be pointed at another array passed in by reference
+		if chcked.IsOpen && chcker.IsDynamic {
+			line, col := (*checked).Pos()
+			return NewLangError(OpenArrayNotDynamic, checkedType).At(line, col)
+		}
+		if chcker.IsOpen && !chcked.IsRuntimeSized {
+			line, col := (*checked).Pos()
+			return NewLangError(TypeMismatch, checkedType.String(), (*checker).String()).At(line, col)
+		}
+		// Maps only match maps with the same key and value types
+		if chcker.Kind == ContainerHashMap || chcked.Kind == ContainerHashMap {
+			if !isSameTypeAndName(chcker, chcked) {
+				line, col := (*checked).Pos()
+				return NewLangError(TypeMismatch, checkedType.String(), (*checker).String()).At(line, col)
+			}
+			return nil
+		}
+		if lit, ok := (*checked).(*ArrayLiteral); ok && chcker.Dimensions == 2 {
+			err := tc.AnalyzeMatrixLiteral(chcker, lit)
+			if err != nil {
+				return err
+			}
+			// The shape is compared with the bounds when the program runs
+			if chcker.IsRuntimeSized {
+				lit.Type = chcker
+			}
+			return nil
+		}
 		if chcker.Dimensions != chcked.Dimensions {
 			line, col := (*checked).Pos()
 			return NewLangError(
@@ -209,21 +866,54 @@ func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 				fmt.Sprintf("%s (%d chiều)", checkedType.String(), chcked.Dimensions),
 				fmt.Sprintf("%s (%d chiều) ", (*checker).String(), (*chcker).Dimensions)).At(line, col)
 		}
-		if chcker.IsDynamic {
-			chcker.Bounds = chcked.Bounds
-			*checker = chcker
-		}
 
 		// If is literal then check the element typings
 		lit, ok := (*checked).(*ArrayLiteral)
 		if ok {
+			// Runtime sizes are compared when the program runs
+			shape, fixed := literalShape(chcker)
+			if fixed && shape[0] != len(lit.Elements) {
+				line, col := lit.Pos()
+				return NewLangError(
+					TypeMismatch,
+					fmt.Sprintf("%s (%d phần tử)", (*checker).String(), len(lit.Elements)),
+					fmt.Sprintf("%s (%d phần tử)", (*checker).String(), shape[0])).At(line, col)
+			}
 			// Check type and cast for each elements
-			for _, elem := range lit.Elements {
-				err := tc.AnalyzeType(&chcker.ElementType, &elem)
+			for i := range lit.Elements {
+				err := tc.AnalyzeType(&chcker.ElementType, &lit.Elements[i])
 				if err != nil {
 					return err
 				}
 			}
+			// The literal is built on the heap instead
+			if chcker.IsDynamic {
+				lit.Type = &ContainerType{Kind: chcker.Kind, ElementType: chcker.ElementType, Dimensions: chcker.Dimensions, IsDynamic: true}
+			}
+			if chcker.IsRuntimeSized {
+				lit.Type = chcker
+			}
+			return nil
+		}
+
+		// Fixed, runtime sized and growable arrays all have different layouts
+		if chcker.IsDynamic != chcked.IsDynamic || chcker.IsRuntimeSized != chcked.IsRuntimeSized ||
+			!isSameTypeAndName(chcker.ElementType, chcked.ElementType) {
+			line, col := (*checked).Pos()
+			return NewLangError(TypeMismatch, layoutString(chcked), layoutString(chcker)).At(line, col)
+		}
+		// Fixed size containers also need the same number of elements, their bounds may differ
+		checkerShape, ok1 := literalShape(chcker)
+		checkedShape, ok2 := literalShape(chcked)
+		if ok1 && ok2 && !slices.Equal(checkerShape, checkedShape) {
+			line, col := (*checked).Pos()
+			if chcker.Dimensions == 2 {
+				return NewLangError(MatrixShapeMismatch, checkedShape[0], checkedShape[1], checkerShape[0], checkerShape[1]).At(line, col)
+			}
+			return NewLangError(
+				TypeMismatch,
+				fmt.Sprintf("%s (%d phần tử)", checkedType.String(), checkedShape[0]),
+				fmt.Sprintf("%s (%d phần tử)", (*checker).String(), checkerShape[0])).At(line, col)
 		}
 		return nil
 	}
@@ -236,7 +926,7 @@ func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 
 	// If is same type then ok
 	if isSameTypeAndName(*checker, checkedType) {
-		return nil
+		return checkLiteralRange(*checked)
 	}
 
 	// Handle if initializer is a literal
@@ -245,17 +935,63 @@ func (tc *TypeChecker) AnalyzeType(checker *Type, checked *Expression) error {
 			line, col := (*checked).Pos()
 			return NewLangError(TypeMismatch, checkedType.String(), (*checker).String()).At(line, col)
 		}
-	} else if !canImplicitCast(checkedType, (*checker)) { // Handle if a type can be widen
+		// Finally cast after safty causions
+		return castExpr(*checked, *checker)
+	}
+	if !canImplicitCast(checkedType, (*checker)) { // Handle if a type can be widen
 		line, col := (*checked).Pos()
 		return NewLangError(TypeMismatch, checkedType.String(), (*checker).String()).At(line, col)
 	}
+	*checked = widenExpr(*checked, *checker)
+	return nil
+}
 
-	// Finally cast after safty causions
-	err = castExpr(*checked, *checker)
-	if err != nil {
-		return err
+// Wraps a non literal expression in a cast so codegen actually extends the value
+func widenExpr(expr Expression, toType Type) Expression {
+	toTyp, ok := toType.(*PrimitiveType)
+	if !ok || isSameTypeAndName(getExprType(expr), toType) {
+		return expr
+	}
+	line, col := expr.Pos()
+	return &ExplicitCast{Type: PrimitiveType{Name: toTyp.Name}, Argument: expr, Line: line, Column: col}
+}
+
+// Checks that integer literals fit in the type they ended up with
+func checkLiteralRange(expr Expression) error {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		var err error
+		switch e.Type.Name {
+		case PrimitiveZ32:
+			_, err = strconv.ParseInt(e.Value, 10, 32)
+		case PrimitiveZ64:
+			_, err = strconv.ParseInt(e.Value, 10, 64)
+		case PrimitiveN32:
+			_, err = strconv.ParseUint(e.Value, 10, 32)
+		case PrimitiveN64:
+			_, err = strconv.ParseUint(e.Value, 10, 64)
+		}
+		if err != nil {
+			return NewLangError(LiteralOutOfRange, e.Value, e.Type.Name).At(e.Line, e.Column)
+		}
+		return nil
+	case *CharLiteral:
+		// C8 holds a single UTF-8 byte and C16 a single UTF-16 unit
+		if (e.Type.Name == PrimitiveC8 && e.Value > 0x7F) || (e.Type.Name == PrimitiveC16 && e.Value > 0xFFFF) {
+			return NewLangError(LiteralOutOfRange, string(e.Value), e.Type.Name).At(e.Line, e.Column)
+		}
+		return nil
+	case *BinaryExpr:
+		err := checkLiteralRange(e.Left)
+		if err != nil {
+			return err
+		}
+		return checkLiteralRange(e.Right)
+	case *UnaryExpr:
+		return checkLiteralRange(e.Operand)
+	default:
+		return nil
 	}
-	return nil
 }
 
 func (tc *TypeChecker) AnalyzeExpression(expr Expression) error {
@@ -269,12 +1005,26 @@ func (tc *TypeChecker) AnalyzeExpression(expr Expression) error {
 	case *NumberLiteral:
 		// Is already R64
 		return nil
+	case *UninitializedExpr:
+		return nil
+	case *StringLiteral:
+		return nil
+	case *BooleanLiteral:
+		return nil
+	case *CharLiteral:
+		return nil
 	case *BinaryExpr:
 		err := tc.AnalyzeBinaryExpr(e)
 		if err != nil {
 			return err
 		}
 		return nil
+	case *UnaryExpr:
+		err := tc.AnalyzeUnaryExpr(e)
+		if err != nil {
+			return err
+		}
+		return nil
 	case *CallExpr:
 		err := tc.AnalyzeCallExpr(e)
 		if err != nil {
@@ -299,6 +1049,18 @@ func (tc *TypeChecker) AnalyzeExpression(expr Expression) error {
 			return err
 		}
 		return nil
+	case *StructLiteral:
+		err := tc.AnalyzeStructLiteral(e)
+		if err != nil {
+			return err
+		}
+		return nil
+	case *FieldExpr:
+		err := tc.AnalyzeFieldExpr(e)
+		if err != nil {
+			return err
+		}
+		return nil
 	default:
 		line, col := e.Pos()
 		return NewLangError(UnknownExpression).At(line, col)
@@ -337,15 +1099,153 @@ func (tc *TypeChecker) AnalyzeArrayLiteral(a *ArrayLiteral) error {
 			line, col := elem.Pos()
 			return NewLangError(TypeMismatch, elemType.String(), inferredType.String()).At(line, col)
 		}
+		a.Elements[i] = widenExpr(elem, inferredType)
 	}
 
 	a.Type = &ContainerType{
 		Kind:        ContainerArray,
 		ElementType: inferredType,
-		Bounds: []Expression{
-			&NumberLiteral{Value: "0", Type: PrimitiveType{Name: PrimitiveZ64}},
-			&NumberLiteral{Value: fmt.Sprintf("%d", len(a.Elements)-1), Type: PrimitiveType{Name: PrimitiveZ64}}},
-		Dimensions: 1}
+		Bounds:      zeroBasedBounds(len(a.Elements)),
+		Dimensions:  1}
+	return nil
+}
+
+// A matrix literal is written row by row, for example [[1, 2], [3, 4]]
+func (tc *TypeChecker) AnalyzeMatrixLiteral(matrix *ContainerType, lit *ArrayLiteral) error {
+	shape, known := literalShape(matrix)
+	rows, cols := len(lit.Elements), 0
+	if rows > 0 {
+		if first, ok := lit.Elements[0].(*ArrayLiteral); ok {
+			cols = len(first.Elements)
+		}
+	}
+	if known && (rows != shape[0] || cols != shape[1]) {
+		return NewLangError(MatrixShapeMismatch, rows, cols, shape[0], shape[1]).At(lit.Line, lit.Column)
+	}
+	for _, row := range lit.Elements {
+		rowLit, ok := row.(*ArrayLiteral)
+		if !ok {
+			line, col := row.Pos()
+			return NewLangError(TypeMismatch, tc.getExprType(row).String(), ContainerArray).At(line, col)
+		}
+		// Every row has the same length
+		if len(rowLit.Elements) != cols {
+			return NewLangError(MatrixShapeMismatch, rows, len(rowLit.Elements), rows, cols).At(rowLit.Line, rowLit.Column)
+		}
+		for j := range rowLit.Elements {
+			err := tc.AnalyzeType(&matrix.ElementType, &rowLit.Elements[j])
+			if err != nil {
+				return err
+			}
+		}
+		rowLit.Type = &ContainerType{Kind: ContainerArray, ElementType: matrix.ElementType, Dimensions: 1, Bounds: zeroBasedBounds(cols)}
+	}
+	lit.Type = &ContainerType{Kind: ContainerMatrix, ElementType: matrix.ElementType, Dimensions: 2, Bounds: append(zeroBasedBounds(rows), zeroBasedBounds(cols)...)}
+	return nil
+}
+
+// Bounds 0..n-1, the ones given to literals
+func zeroBasedBounds(n int) []Expression {
+	return []Expression{
+		&NumberLiteral{Value: "0", Type: PrimitiveType{Name: PrimitiveZ64}},
+		&NumberLiteral{Value: fmt.Sprintf("%d", n-1), Type: PrimitiveType{Name: PrimitiveZ64}},
+	}
+}
+
+// Number of elements in each dimension, when every bound is a literal
+// The type with the bounds String leaves out, which tell fixed and runtime sizes apart
+func layoutString(typ *ContainerType) string {
+	if typ.IsDynamic || typ.IsOpen || len(typ.Bounds) != 2*typ.Dimensions {
+		return typ.String()
+	}
+	bounds := make([]string, typ.Dimensions)
+	for d := range bounds {
+		bounds[d] = boundString(typ.Bounds[2*d]) + ".." + boundString(typ.Bounds[2*d+1])
+	}
+	return typ.Kind + "[" + strings.Join(bounds, ",") + "] E " + typ.ElementType.String()
+}
+
+func boundString(expr Expression) string {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		return e.Value
+	case *Identifier:
+		return e.Name
+	case *ExplicitCast:
+		return boundString(e.Argument)
+	case *UnaryExpr:
+		return e.Operator + boundString(e.Operand)
+	case *BinaryExpr:
+		return boundString(e.Left) + " " + e.Operator + " " + boundString(e.Right)
+	}
+	return "..."
+}
+
+func literalShape(typ *ContainerType) ([]int, bool) {
+	if typ.IsDynamic || len(typ.Bounds) != 2*typ.Dimensions {
+		return nil, false
+	}
+	shape := make([]int, typ.Dimensions)
+	for d := range shape {
+		lower, ok1 := typ.Bounds[2*d].(*NumberLiteral)
+		upper, ok2 := typ.Bounds[2*d+1].(*NumberLiteral)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		lo, err1 := strconv.Atoi(lower.Value)
+		hi, err2 := strconv.Atoi(upper.Value)
+		if err1 != nil || err2 != nil {
+			return nil, false
+		}
+		shape[d] = hi - lo + 1
+	}
+	return shape, true
+}
+
+func (tc *TypeChecker) AnalyzeStructLiteral(s *StructLiteral) error {
+	st, ok := tc.Structs[s.StructName]
+	if !ok {
+		return NewLangError(UnknownStructType, s.StructName).At(s.Line, s.Column)
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Fields)) {
+		if _, ok := st.Fields[name]; !ok {
+			line, col := s.Fields[name].Pos()
+			return NewLangError(UnknownStructField, st.Name, name).At(line, col)
+		}
+	}
+	// Fields left out are zeroed
+	for _, name := range st.Order {
+		expr, ok := s.Fields[name]
+		if !ok {
+			continue
+		}
+		fieldType := copyType(st.Fields[name])
+		err := tc.AnalyzeType(&fieldType, &expr)
+		if err != nil {
+			return err
+		}
+		s.Fields[name] = expr
+	}
+	s.Type = st
+	return nil
+}
+
+func (tc *TypeChecker) AnalyzeFieldExpr(f *FieldExpr) error {
+	err := tc.AnalyzeExpression(f.Object)
+	if err != nil {
+		return err
+	}
+	objType := tc.getExprType(f.Object)
+	st, ok := objType.(*StructType)
+	if !ok {
+		return NewLangError(InvalidFieldAccess, f.Field, objType.String()).At(f.Line, f.Column)
+	}
+	fieldType, ok := st.Fields[f.Field]
+	if !ok {
+		return NewLangError(UnknownStructField, st.Name, f.Field).At(f.Line, f.Column)
+	}
+	f.Index = slices.Index(st.Order, f.Field)
+	f.Type = fieldType
 	return nil
 }
 
@@ -396,6 +1296,16 @@ func (tc *TypeChecker) AnalyzeBinaryExpr(b *BinaryExpr) error {
 	}
 	leftType := tc.getExprType(b.Left)
 	rightType := tc.getExprType(b.Right)
+	if b.Operator == SymbolEqual || b.Operator == SymbolNotEqual {
+		leftContainer, ok1 := leftType.(*ContainerType)
+		rightContainer, ok2 := rightType.(*ContainerType)
+		if ok1 && ok2 {
+			return tc.AnalyzeContainerEquality(b, leftContainer, rightContainer)
+		}
+	}
+	if isMatrixType(leftType) || isMatrixType(rightType) {
+		return tc.AnalyzeMatrixBinaryExpr(b, leftType, rightType)
+	}
 	leftTyp, ok1 := leftType.(*PrimitiveType)
 	rightTyp, ok2 := rightType.(*PrimitiveType)
 	if !ok1 || !ok2 {
@@ -414,39 +1324,210 @@ func (tc *TypeChecker) AnalyzeBinaryExpr(b *BinaryExpr) error {
 		return nil
 	}
 
-	if leftTyp.Name == rightTyp.Name {
-		b.ReturnType.Name = leftTyp.Name
+	// Strings can only be compared for equality
+	if leftTyp.Name == PrimitiveS8 || rightTyp.Name == PrimitiveS8 {
+		if leftTyp.Name != rightTyp.Name || (b.Operator != SymbolEqual && b.Operator != SymbolNotEqual) {
+			return NewLangError(ErrorBinaryExpr, leftTyp.Name, rightTyp.Name).At(b.Line, b.Column)
+		}
+		b.ReturnType.Name = PrimitiveB1
 		return nil
 	}
 
-	if isLiteral(b.Left) && !isLiteral(b.Right) {
-		if !canLiteralCast(leftType, rightType) {
-			return NewLangError(ErrorBinaryExpr, leftType, rightType).At(b.Line, b.Column)
-		}
-		castExpr(b.Left, rightType)
-		leftTyp.Name = rightTyp.Name
-	} else if !isLiteral(b.Left) && isLiteral(b.Right) {
-		if !canLiteralCast(rightType, leftType) {
+	if leftTyp.Name != rightTyp.Name {
+		if isLiteral(b.Left) && !isLiteral(b.Right) {
+			if !canLiteralCast(leftType, rightType) {
+				return NewLangError(ErrorBinaryExpr, leftType, rightType).At(b.Line, b.Column)
+			}
+			err := castExpr(b.Left, rightType)
+			if err != nil {
+				return err
+			}
+			leftTyp.Name = rightTyp.Name
+		} else if !isLiteral(b.Left) && isLiteral(b.Right) {
+			if !canLiteralCast(rightType, leftType) {
+				return NewLangError(ErrorBinaryExpr, rightType, leftType).At(b.Line, b.Column)
+			}
+			err := castExpr(b.Right, leftType)
+			if err != nil {
+				return err
+			}
+			rightTyp.Name = leftTyp.Name
+		} else if canImplicitCast(rightType, leftType) {
+			// The narrower side is widened to the other
+			b.Right = widenExpr(b.Right, leftType)
+		} else if canImplicitCast(leftType, rightType) {
+			b.Left = widenExpr(b.Left, rightType)
+			leftTyp = rightTyp
+		} else {
 			return NewLangError(ErrorBinaryExpr, rightType, leftType).At(b.Line, b.Column)
 		}
-		castExpr(b.Right, leftType)
-		rightTyp.Name = leftTyp.Name
-	}
-
-	if leftType != rightType && !canImplicitCast(rightType, leftType) {
-		return NewLangError(ErrorBinaryExpr, rightType, leftType).At(b.Line, b.Column)
 	}
 
 	switch b.Operator {
-	case SymbolLess, SymbolLessEqual, SymbolGreater, SymbolGreaterEqual, SymbolEqual, SymbolNotEqual:
+	case SymbolEqual, SymbolNotEqual:
+		b.ReturnType.Name = PrimitiveB1
+		return nil
+	case SymbolLess, SymbolLessEqual, SymbolGreater, SymbolGreaterEqual:
+		// B1 has no order
+		if leftTyp.Name == PrimitiveB1 {
+			return NewLangError(InvalidOperand, b.Operator, leftTyp.Name).At(b.Line, b.Column)
+		}
 		b.ReturnType.Name = PrimitiveB1
 		return nil
+	case SymbolPlus, SymbolMinus, SymbolAsterisk, SymbolSlash, SymbolModulo, SymbolCaret:
+		if !isTypeNumber_Type(leftTyp) {
+			return NewLangError(InvalidOperand, b.Operator, leftTyp.Name).At(b.Line, b.Column)
+		}
+		b.ReturnType.Name = leftTyp.Name
+		return nil
+	case SymbolAmpersand, SymbolPipe, KeywordXor, SymbolShiftLeft, SymbolShiftRight:
+		// Bitwise operators only make sense on integers
+		if !isTypeInteger_Type(leftTyp) {
+			return NewLangError(InvalidOperand, b.Operator, leftTyp.Name).At(b.Line, b.Column)
+		}
+		b.ReturnType.Name = leftTyp.Name
+		return nil
 	default:
 		b.ReturnType.Name = leftTyp.Name
 		return nil
 	}
 }
 
+// Matrix arithmetic: element-wise + and -, scaling by a number and the matrix product
+func (tc *TypeChecker) AnalyzeMatrixBinaryExpr(b *BinaryExpr, leftType, rightType Type) error {
+	leftMatrix, leftOk := leftType.(*ContainerType)
+	rightMatrix, rightOk := rightType.(*ContainerType)
+
+	// The number of a scaling can be on either side
+	if b.Operator == SymbolAsterisk && (!leftOk || !rightOk) {
+		matrix, scalar := leftMatrix, &b.Right
+		if !leftOk {
+			matrix, scalar = rightMatrix, &b.Left
+		}
+		err := checkMatrixOperand(b, matrix)
+		if err != nil {
+			return err
+		}
+		err = tc.AnalyzeType(&matrix.ElementType, scalar)
+		if err != nil {
+			return err
+		}
+		b.MatrixType = matrix
+		return nil
+	}
+
+	if !leftOk || !rightOk {
+		matrixType := leftType
+		if !isMatrixType(leftType) {
+			matrixType = rightType
+		}
+		return NewLangError(InvalidOperand, b.Operator, matrixType).At(b.Line, b.Column)
+	}
+	err := checkMatrixOperand(b, leftMatrix)
+	if err != nil {
+		return err
+	}
+	err = checkMatrixOperand(b, rightMatrix)
+	if err != nil {
+		return err
+	}
+	if !isSameTypeAndName(leftMatrix.ElementType, rightMatrix.ElementType) {
+		return NewLangError(ErrorBinaryExpr, leftType, rightType).At(b.Line, b.Column)
+	}
+
+	// Shapes that aren't known here are checked when the program runs
+	leftShape, ok1 := literalShape(leftMatrix)
+	rightShape, ok2 := literalShape(rightMatrix)
+	known := ok1 && ok2
+	switch b.Operator {
+	case SymbolPlus, SymbolMinus:
+		if known && (leftShape[0] != rightShape[0] || leftShape[1] != rightShape[1]) {
+			line, col := b.Right.Pos()
+			return NewLangError(MatrixShapeMismatch, rightShape[0], rightShape[1], leftShape[0], leftShape[1]).At(line, col)
+		}
+		b.MatrixType = leftMatrix
+	case SymbolAsterisk:
+		if known && leftShape[1] != rightShape[0] {
+			return NewLangError(MatrixProductMismatch, leftShape[0], leftShape[1], rightShape[0], rightShape[1]).At(b.Line, b.Column)
+		}
+		// Rows of the left side and columns of the right side
+		b.MatrixType = &ContainerType{
+			Kind:           ContainerMatrix,
+			ElementType:    leftMatrix.ElementType,
+			Dimensions:     2,
+			Bounds:         []Expression{leftMatrix.Bounds[0], leftMatrix.Bounds[1], rightMatrix.Bounds[2], rightMatrix.Bounds[3]},
+			IsRuntimeSized: leftMatrix.IsRuntimeSized || rightMatrix.IsRuntimeSized}
+	default:
+		return NewLangError(InvalidOperand, b.Operator, leftType).At(b.Line, b.Column)
+	}
+	return nil
+}
+
+// Arrays or matrices are equal when they have the same shape and the same elements, their bounds may differ
+func (tc *TypeChecker) AnalyzeContainerEquality(b *BinaryExpr, left, right *ContainerType) error {
+	// A literal on one side takes the element type of the other
+	if lit, ok := b.Right.(*ArrayLiteral); ok && left.Kind == ContainerArray && len(lit.Elements) > 0 {
+		err := tc.retypeArrayLiteral(lit, left.ElementType)
+		if err != nil {
+			return err
+		}
+		right = lit.Type.(*ContainerType)
+	} else if lit, ok := b.Left.(*ArrayLiteral); ok && right.Kind == ContainerArray && len(lit.Elements) > 0 {
+		err := tc.retypeArrayLiteral(lit, right.ElementType)
+		if err != nil {
+			return err
+		}
+		left = lit.Type.(*ContainerType)
+	}
+	_, primitive := left.ElementType.(*PrimitiveType)
+	if left.Kind == ContainerHashMap || left.Kind != right.Kind || !primitive ||
+		!isSameTypeAndName(left.ElementType, right.ElementType) || (left.Kind == ContainerMatrix && left.IsDynamic) {
+		return NewLangError(ErrorBinaryExpr, left, right).At(b.Line, b.Column)
+	}
+	b.ReturnType.Name = PrimitiveB1
+	return nil
+}
+
+// Only fixed size matrices of numbers take part in arithmetic
+func checkMatrixOperand(b *BinaryExpr, matrix *ContainerType) error {
+	if !isMatrixType(matrix) || matrix.IsDynamic || len(matrix.Bounds) != 4 || !isTypeNumber_Type(matrix.ElementType) {
+		return NewLangError(InvalidOperand, b.Operator, matrix).At(b.Line, b.Column)
+	}
+	return nil
+}
+
+func isMatrixType(typ Type) bool {
+	containerType, ok := typ.(*ContainerType)
+	return ok && containerType.Kind == ContainerMatrix
+}
+
+func (tc *TypeChecker) AnalyzeUnaryExpr(u *UnaryExpr) error {
+	err := tc.AnalyzeExpression(u.Operand)
+	if err != nil {
+		return err
+	}
+	operandType := tc.getExprType(u.Operand)
+	typ, ok := operandType.(*PrimitiveType)
+	if !ok {
+		return NewLangError(ExpectToken, "kiểu dữ liệu nguyên thuỷ").At(u.Line, u.Column)
+	}
+	switch u.Operator {
+	case SymbolMinus:
+		// Negating an unsigned number makes no sense
+		switch typ.Name {
+		case PrimitiveZ32, PrimitiveZ64, PrimitiveR32, PrimitiveR64:
+		default:
+			return NewLangError(InvalidOperand, u.Operator, typ.Name).At(u.Line, u.Column)
+		}
+	case SymbolBang:
+		if typ.Name != PrimitiveB1 {
+			return NewLangError(InvalidOperand, u.Operator, typ.Name).At(u.Line, u.Column)
+		}
+	}
+	u.ReturnType.Name = typ.Name
+	return nil
+}
+
 func (tc *TypeChecker) AnalyzeCallExpr(c *CallExpr) error {
 	// Resolve function symbol
 	f, found := tc.GlobalScope.Resolve(c.Name)
@@ -462,6 +1543,12 @@ func (tc *TypeChecker) AnalyzeCallExpr(c *CallExpr) error {
 		return NewLangError(InvalidFunctionCall, c.Name).At(line, col)
 	}
 
+	// sắp_xếp(a) sorts in ascending order
+	if c.Name == "sắp_xếp" && len(c.Arguments) == 1 {
+		line, col := c.Pos()
+		c.Arguments = append(c.Arguments, &BooleanLiteral{Value: false, Type: PrimitiveType{Name: PrimitiveB1}, Line: line, Column: col})
+	}
+
 	// FIXME: Fix for "tuỳ" type parameter
 	// Check argument count
 	if len(c.Arguments) != len(fn.Parameters) {
@@ -470,9 +1557,16 @@ func (tc *TypeChecker) AnalyzeCallExpr(c *CallExpr) error {
 	}
 
 	// Check argument types
-	for i, arg := range c.Arguments {
+	for i := range c.Arguments {
 		paramType := fn.Parameters[i].Type
-		err := tc.AnalyzeType(&paramType, &arg)
+		if open, ok := paramType.(*ContainerType); ok && open.IsOpen {
+			err := tc.AnalyzeOpenArgument(open, c.Arguments[i])
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := tc.AnalyzeType(&paramType, &c.Arguments[i])
 		if err != nil {
 			// fmt.Println("Bruh")
 			return err
@@ -480,9 +1574,210 @@ func (tc *TypeChecker) AnalyzeCallExpr(c *CallExpr) error {
 	}
 
 	c.ReturnType = fn.ReturnType
+	switch c.Name {
+	case "thêm", "độ_dài", "sàn", "trần":
+		return tc.AnalyzeArrayBuiltin(c)
+	case "chuyển_vị", "đơn_vị", "định_thức":
+		return tc.AnalyzeMatrixBuiltin(c)
+	case "tìm", "xoá", "các_khoá":
+		return tc.AnalyzeMapBuiltin(c)
+	case "sắp_xếp", "tìm_nhị_phân", "nhỏ_nhất", "lớn_nhất", "đảo_ngược":
+		return tc.AnalyzeOrderBuiltin(c)
+	}
+	return nil
+}
+
+// Fixed, runtime sized and growable arrays can all be passed as an open array.
+// Literals keep the bounds 0..n-1
+func (tc *TypeChecker) AnalyzeOpenArgument(param *ContainerType, arg Expression) error {
+	err := tc.AnalyzeExpression(arg)
+	if err != nil {
+		return err
+	}
+	if lit, ok := arg.(*ArrayLiteral); ok && len(lit.Elements) > 0 {
+		return tc.retypeArrayLiteral(lit, param.ElementType)
+	}
+	argType := tc.getExprType(arg)
+	arrType, ok := argType.(*ContainerType)
+	if !ok || arrType.Kind != ContainerArray || !isSameTypeAndName(arrType.ElementType, param.ElementType) {
+		line, col := arg.Pos()
+		return NewLangError(TypeMismatch, argType, param).At(line, col)
+	}
+	return nil
+}
+
+// Casts the elements of a literal to elemType, the literal keeps the bounds 0..n-1
+func (tc *TypeChecker) retypeArrayLiteral(lit *ArrayLiteral, elemType Type) error {
+	for i := range lit.Elements {
+		err := tc.AnalyzeType(&elemType, &lit.Elements[i])
+		if err != nil {
+			return err
+		}
+	}
+	lit.Type = &ContainerType{Kind: ContainerArray, ElementType: elemType, Dimensions: 1, Bounds: zeroBasedBounds(len(lit.Elements))}
+	return nil
+}
+
+// Map builtins take "tuỳ", so the map and its key are checked here
+func (tc *TypeChecker) AnalyzeMapBuiltin(c *CallExpr) error {
+	argType := tc.getExprType(c.Arguments[0])
+	mapType, ok := argType.(*ContainerType)
+	if !ok || mapType.Kind != ContainerHashMap {
+		line, col := c.Arguments[0].Pos()
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerHashMap, argType).At(line, col)
+	}
+	if c.Name == "các_khoá" {
+		// The keys come back as a growable array, in no particular order
+		c.ReturnType = &ContainerType{Kind: ContainerArray, ElementType: mapType.KeyType, Dimensions: 1, IsDynamic: true}
+		return nil
+	}
+
+	err := tc.AnalyzeType(&mapType.KeyType, &c.Arguments[1])
+	if err != nil {
+		return err
+	}
+	// xoá changes the map and tìm writes the value it found
+	target := c.Arguments[0]
+	if c.Name == "tìm" {
+		target = c.Arguments[2]
+	}
+	switch target.(type) {
+	case *Identifier, *IndexExpr, *FieldExpr:
+	default:
+		line, col := target.Pos()
+		return NewLangError(InvalidAssignTarget).At(line, col)
+	}
+	if c.Name == "tìm" && !isSameTypeAndName(tc.getExprType(target), mapType.ElementType) {
+		line, col := target.Pos()
+		return NewLangError(TypeMismatch, tc.getExprType(target), mapType.ElementType).At(line, col)
+	}
+	return nil
+}
+
+// Keys are hashed by value, strings by their characters
+func isMapKeyType(typ Type) bool {
+	primitive, ok := typ.(*PrimitiveType)
+	if !ok {
+		return false
+	}
+	switch primitive.Name {
+	case PrimitiveS8, PrimitiveB1, PrimitiveZ32, PrimitiveZ64, PrimitiveN32, PrimitiveN64, PrimitiveC8, PrimitiveC16, PrimitiveC32:
+		return true
+	}
+	return false
+}
+
+// Matrix builtins work out the shape of their result from their argument
+func (tc *TypeChecker) AnalyzeMatrixBuiltin(c *CallExpr) error {
+	line, col := c.Arguments[0].Pos()
+	// The size of đơn_vị(n) is part of the type, so n has to be known here
+	if c.Name == "đơn_vị" {
+		size, ok := c.Arguments[0].(*NumberLiteral)
+		if !ok {
+			return NewLangError(InvalidMatrixSize).At(line, col)
+		}
+		n, err := strconv.Atoi(size.Value)
+		if err != nil || n <= 0 {
+			return NewLangError(InvalidMatrixSize).At(line, col)
+		}
+		c.ReturnType = &ContainerType{
+			Kind:        ContainerMatrix,
+			ElementType: &PrimitiveType{Name: PrimitiveR64},
+			Dimensions:  2,
+			Bounds:      append(zeroBasedBounds(n), zeroBasedBounds(n)...)}
+		return nil
+	}
+
+	argType := tc.getExprType(c.Arguments[0])
+	matrix, ok := argType.(*ContainerType)
+	if !ok || matrix.Kind != ContainerMatrix || matrix.IsDynamic || len(matrix.Bounds) != 4 {
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerMatrix, argType).At(line, col)
+	}
+	if c.Name == "chuyển_vị" {
+		c.ReturnType = &ContainerType{
+			Kind:           ContainerMatrix,
+			ElementType:    matrix.ElementType,
+			Dimensions:     2,
+			Bounds:         []Expression{matrix.Bounds[2], matrix.Bounds[3], matrix.Bounds[0], matrix.Bounds[1]},
+			IsRuntimeSized: matrix.IsRuntimeSized}
+		return nil
+	}
+
+	// định_thức needs a square matrix of reals
+	if !isSameTypeAndName(matrix.ElementType, &PrimitiveType{Name: PrimitiveR64}) {
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerMatrix+" E "+PrimitiveR64, argType).At(line, col)
+	}
+	if shape, known := literalShape(matrix); known && shape[0] != shape[1] {
+		return NewLangError(MatrixShapeMismatch, shape[0], shape[1], shape[0], shape[0]).At(line, col)
+	}
+	return nil
+}
+
+// Array builtins take "tuỳ", so the array argument is checked here
+func (tc *TypeChecker) AnalyzeArrayBuiltin(c *CallExpr) error {
+	argType := tc.getExprType(c.Arguments[0])
+	arrType, ok := argType.(*ContainerType)
+	// độ_dài also counts the entries of a map
+	if ok && arrType.Kind == ContainerHashMap && c.Name == "độ_dài" {
+		return nil
+	}
+	if !ok || arrType.Kind != ContainerArray {
+		line, col := c.Arguments[0].Pos()
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerArray, argType.String()).At(line, col)
+	}
+	if c.Name != "thêm" {
+		return nil
+	}
+
+	// Only dynamic arrays can grow, and the array itself has to be updated
+	if arrType.IsOpen {
+		line, col := c.Arguments[0].Pos()
+		return NewLangError(OpenArrayNotDynamic, argType).At(line, col)
+	}
+	if !arrType.IsDynamic {
+		line, col := c.Arguments[0].Pos()
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerArray+"[]", argType.String()).At(line, col)
+	}
+	switch c.Arguments[0].(type) {
+	case *Identifier, *IndexExpr, *FieldExpr:
+	default:
+		line, col := c.Arguments[0].Pos()
+		return NewLangError(InvalidAssignTarget).At(line, col)
+	}
+	elemType := arrType.ElementType
+	return tc.AnalyzeType(&elemType, &c.Arguments[1])
+}
+
+// Builtins that order or search the elements of a one dimensional array.
+// Only numbers, characters and strings can be ordered, đảo_ngược takes any element
+func (tc *TypeChecker) AnalyzeOrderBuiltin(c *CallExpr) error {
+	line, col := c.Arguments[0].Pos()
+	argType := tc.getExprType(c.Arguments[0])
+	arrType, ok := argType.(*ContainerType)
+	if !ok || arrType.Kind != ContainerArray || arrType.Dimensions != 1 {
+		return NewLangError(InvalidBuiltinArgument, c.Name, ContainerArray, argType).At(line, col)
+	}
+	if c.Name == "đảo_ngược" {
+		return nil
+	}
+	if !isOrderedType(arrType.ElementType) {
+		return NewLangError(UnorderedElement, c.Name, arrType.ElementType).At(line, col)
+	}
+	switch c.Name {
+	case "tìm_nhị_phân":
+		elemType := arrType.ElementType
+		return tc.AnalyzeType(&elemType, &c.Arguments[1])
+	case "nhỏ_nhất", "lớn_nhất":
+		c.ReturnType = copyType(arrType.ElementType)
+	}
 	return nil
 }
 
+func isOrderedType(typ Type) bool {
+	primitive, ok := typ.(*PrimitiveType)
+	return ok && (isTypeNumber_Type(primitive) || isTypeChar_Type(primitive) || primitive.Name == PrimitiveS8)
+}
+
 func (tc *TypeChecker) AnalyzeIndexExpr(i *IndexExpr) error {
 	containerType := ContainerType{}
 
@@ -499,8 +1794,8 @@ func (tc *TypeChecker) AnalyzeIndexExpr(i *IndexExpr) error {
 			return NewLangError(InvalidArrayAccessType).At(line, col)
 		}
 		containerType = *contain
-	case *IndexExpr:
-		err := tc.AnalyzeIndexExpr(collec) // I think it's okay?
+	case *IndexExpr, *FieldExpr, *CallExpr:
+		err := tc.AnalyzeExpression(collec) // I think it's okay?
 		if err != nil {
 			return err
 		}
@@ -515,16 +1810,37 @@ func (tc *TypeChecker) AnalyzeIndexExpr(i *IndexExpr) error {
 		line, col := i.Pos()
 		return NewLangError(InvalidArrayAccessType).At(line, col)
 	}
+	// Only arrays can be sliced
+	if i.SliceEnd != nil && containerType.Kind != ContainerArray {
+		line, col := i.Pos()
+		return NewLangError(InvalidSlice, containerType.String()).At(line, col)
+	}
 	// Check indexing dimension
 	if len(i.Indices) != containerType.Dimensions {
 		line, col := i.Pos()
 		return NewLangError(InvalidArrayAccessDim, len(i.Indices), containerType.Dimensions).At(line, col)
 	}
 
+	// Maps are indexed by their keys
+	if containerType.Kind == ContainerHashMap {
+		return tc.AnalyzeType(&containerType.KeyType, &i.Indices[0])
+	}
+
+	// A slice refers to the elements of the array, like an open array parameter
+	indices := i.Indices
+	if i.SliceEnd != nil {
+		indices = append([]Expression{}, i.Indices[0], i.SliceEnd)
+		i.SliceType = &ContainerType{Kind: ContainerArray, ElementType: containerType.ElementType, Dimensions: 1, IsRuntimeSized: true, IsOpen: true}
+	}
+
 	// Check indexing type
-	for _, index := range i.Indices {
+	for _, index := range indices {
+		err := tc.AnalyzeExpression(index)
+		if err != nil {
+			return err
+		}
 		typ := tc.getExprType(index)
-		if !isTypeNumber_Type(typ) {
+		if !isTypeInteger_Type(typ) {
 			line, col := index.Pos()
 			return NewLangError(InvalidArrayAccessIndex).At(line, col)
 		}
@@ -551,7 +1867,18 @@ func (tc *TypeChecker) getExprType(expr Expression) Type {
 		}
 	case *NumberLiteral:
 		return &e.Type
+	case *StringLiteral:
+		return &e.Type
+	case *BooleanLiteral:
+		return &e.Type
+	case *CharLiteral:
+		return &e.Type
 	case *BinaryExpr:
+		if e.MatrixType != nil {
+			return e.MatrixType
+		}
+		return &e.ReturnType
+	case *UnaryExpr:
 		return &e.ReturnType
 	case *CallExpr:
 		return e.ReturnType
@@ -559,7 +1886,14 @@ func (tc *TypeChecker) getExprType(expr Expression) Type {
 		return &e.Type
 	case *ArrayLiteral:
 		return e.Type
+	case *StructLiteral:
+		return e.Type
+	case *FieldExpr:
+		return e.Type
 	case *IndexExpr:
+		if e.SliceType != nil {
+			return e.SliceType
+		}
 		switch collec := e.Collection.(type) {
 		case *Identifier:
 			typ, ok := collec.Type.(*ContainerType)
@@ -568,7 +1902,7 @@ func (tc *TypeChecker) getExprType(expr Expression) Type {
 				panic(NewLangError(InvalidArrayAccessType).At(line, col))
 			}
 			return typ.ElementType
-		case *IndexExpr:
+		case *IndexExpr, *FieldExpr, *CallExpr:
 			typ := tc.getExprType(collec)
 			containerType, ok := typ.(*ContainerType)
 			if !ok {
@@ -588,15 +1922,67 @@ func (tc *TypeChecker) getExprType(expr Expression) Type {
 
 func isLiteral(expr Expression) bool {
 	switch e := expr.(type) {
-	case *NumberLiteral:
+	case *NumberLiteral, *CharLiteral:
 		return true
 	case *BinaryExpr:
 		return isLiteral(e.Left) && isLiteral(e.Right)
+	case *UnaryExpr:
+		return isLiteral(e.Operand)
+	default:
+		return false
+	}
+}
+
+// Checks if an expression can be folded into an LLVM constant
+func isConstantExpr(expr Expression) bool {
+	switch e := expr.(type) {
+	case *NumberLiteral, *StringLiteral, *BooleanLiteral, *CharLiteral, *UninitializedExpr:
+		return true
+	case *ArrayLiteral:
+		for _, elem := range e.Elements {
+			if !isConstantExpr(elem) {
+				return false
+			}
+		}
+		return true
+	case *StructLiteral:
+		for _, field := range e.Fields {
+			if !isConstantExpr(field) {
+				return false
+			}
+		}
+		return true
 	default:
 		return false
 	}
 }
 
+// Checks if a struct holds a struct named target by value, directly or through its fields
+func structContains(st *StructType, target string, visited map[string]bool) bool {
+	if visited[st.Name] {
+		return false
+	}
+	visited[st.Name] = true
+	for _, name := range st.Order {
+		fieldType := st.Fields[name]
+		for {
+			container, ok := fieldType.(*ContainerType)
+			if !ok {
+				break
+			}
+			fieldType = container.ElementType
+		}
+		inner, ok := fieldType.(*StructType)
+		if !ok {
+			continue
+		}
+		if inner.Name == target || structContains(inner, target, visited) {
+			return true
+		}
+	}
+	return false
+}
+
 // Handles explicit casting of primitive types
 func canExplicitCast(fromType, toType Type) bool {
 	if isSameTypeAndName(fromType, toType) {
@@ -611,13 +1997,37 @@ func canExplicitCast(fromType, toType Type) bool {
 	}
 
 	switch fromTyp.Name {
-	case PrimitiveR64, PrimitiveR32, PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32:
+	case PrimitiveR64, PrimitiveR32:
 		switch toTyp.Name {
 		case PrimitiveR64, PrimitiveR32, PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32:
 			return true
 		default:
 			return false
 		}
+	case PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32:
+		switch toTyp.Name {
+		case PrimitiveR64, PrimitiveR32, PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32, PrimitiveB1,
+			PrimitiveC8, PrimitiveC16, PrimitiveC32:
+			return true
+		default:
+			return false
+		}
+	case PrimitiveC8, PrimitiveC16, PrimitiveC32:
+		// Characters convert to and from their code point
+		switch toTyp.Name {
+		case PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32, PrimitiveC8, PrimitiveC16, PrimitiveC32:
+			return true
+		default:
+			return false
+		}
+	case PrimitiveB1:
+		// đúng is 1 and sai is 0
+		switch toTyp.Name {
+		case PrimitiveZ64, PrimitiveZ32, PrimitiveN64, PrimitiveN32:
+			return true
+		default:
+			return false
+		}
 	default:
 		return false
 	}
@@ -646,6 +2056,13 @@ func canLiteralCast(fromType, toType Type) bool {
 		default:
 			return false
 		}
+	case PrimitiveC32:
+		switch toTyp.Name {
+		case PrimitiveC32, PrimitiveC16, PrimitiveC8:
+			return true
+		default:
+			return false
+		}
 	default:
 		return false
 	}
@@ -714,6 +2131,20 @@ func canImplicitCast(fromType, toType Type) bool {
 		default:
 			return false
 		}
+	case PrimitiveC8:
+		switch toTypName {
+		case PrimitiveC8, PrimitiveC16, PrimitiveC32:
+			return true
+		default:
+			return false
+		}
+	case PrimitiveC16:
+		switch toTypName {
+		case PrimitiveC16, PrimitiveC32:
+			return true
+		default:
+			return false
+		}
 	default:
 		return false
 	}
@@ -730,7 +2161,14 @@ func castExpr(expr Expression, toType Type) error {
 			return NewLangError(InvalidCasting, e.Type, toType).At(e.Line, e.Column)
 		}
 		e.Type.Name = toTyp.Name
-		return nil
+		return checkLiteralRange(e)
+	case *CharLiteral:
+		toTyp, ok := toType.(*PrimitiveType)
+		if !ok || !isTypeChar_Type(toTyp) {
+			return NewLangError(InvalidCasting, e.Type, toType).At(e.Line, e.Column)
+		}
+		e.Type.Name = toTyp.Name
+		return checkLiteralRange(e)
 	case *BinaryExpr:
 		err := castExpr(e.Left, toType)
 		if err != nil {
@@ -746,6 +2184,17 @@ func castExpr(expr Expression, toType Type) error {
 		}
 		e.ReturnType.Name = toTyp.Name
 		return nil
+	case *UnaryExpr:
+		err := castExpr(e.Operand, toType)
+		if err != nil {
+			return err
+		}
+		toTyp, ok := toType.(*PrimitiveType)
+		if !ok || !isTypeNumber_Type(toTyp) {
+			return NewLangError(InvalidCasting, e.ReturnType, toType).At(e.Line, e.Column)
+		}
+		e.ReturnType.Name = toTyp.Name
+		return nil
 	case *CallExpr:
 		e.ReturnType = toType
 		return nil
@@ -7,6 +7,11 @@ const (
 	SymbolAsterisk     = "*"
 	SymbolSlash        = "/"
 	SymbolModulo       = "%"
+	SymbolCaret        = "^"
+	SymbolAmpersand    = "&"
+	SymbolPipe         = "|"
+	SymbolShiftLeft    = "<<"
+	SymbolShiftRight   = ">>"
 	SymbolBang         = "!"
 	SymbolLess         = "<"
 	SymbolGreater      = ">"
@@ -8,6 +8,7 @@ const (
 	TokenIdent     TokenType = "IDENTIFIER"
 	TokenNumber    TokenType = "NUMBER"
 	TokenString    TokenType = "STRING"
+	TokenChar      TokenType = "CHAR"
 	TokenOperator  TokenType = "OPERATOR"
 	TokenLParen    TokenType = "LPAREN"
 	TokenRParen    TokenType = "RPAREN"
@@ -16,6 +17,8 @@ const (
 	TokenLBrace    TokenType = "LBRACE"
 	TokenRBrace    TokenType = "RBRACE"
 	TokenComma     TokenType = "COMMA"
+	TokenColon     TokenType = "COLON"
+	TokenDot       TokenType = "DOT"
 	TokenSemiColon TokenType = "SEMICOLON"
 	TokenNewLine   TokenType = "NEWLINE"
 	TokenPrimitive TokenType = "PRIMITIVE"
@@ -36,6 +39,9 @@ var Operators = map[string]string{
 	"*": SymbolAsterisk,
 	"/": SymbolSlash,
 	"%": SymbolModulo,
+	"^": SymbolCaret,
+	"&": SymbolAmpersand,
+	"|": SymbolPipe,
 	"!": SymbolBang,
 	"=": SymbolEqual,
 	"<": SymbolLess,
@@ -51,4 +57,6 @@ var Symbols = map[string]TokenType{
 	"}": TokenRBrace,
 	";": TokenSemiColon,
 	",": TokenComma,
+	":": TokenColon,
+	".": TokenDot,
 }
@@ -12,6 +12,20 @@ import (
 // Helper functions
 func printProgram(p *Program) {
 	fmt.Println("Program:")
+	for _, st := range p.Structs {
+		fmt.Printf("  Struct: %s (Line %d, Column %d)\n", st.Name, st.Line, st.Column)
+		for _, field := range st.Fields {
+			fmt.Printf("      - %s: %s (Line %d, Column %d)\n", field.Name, field.Type.String(), field.Line, field.Column)
+		}
+		fmt.Println("")
+	}
+	if len(p.Globals) > 0 {
+		fmt.Println("  Globals:")
+		for _, global := range p.Globals {
+			printStatement(global, "    ")
+		}
+		fmt.Println("")
+	}
 	for _, fn := range p.Functions {
 		printFunction(fn)
 	}
@@ -37,6 +51,10 @@ func printStatement(s Statement, indent string) {
 		fmt.Printf("%sVarDecl: %s: %s = ", indent, stmt.Var.Name, stmt.Var.Type.String())
 		printExpression(stmt.Value, "")
 		fmt.Printf(" (Line %d, Column %d)\n", stmt.Line, stmt.Column)
+	case *VarDeclList:
+		for _, decl := range stmt.Decls {
+			printStatement(decl, indent)
+		}
 	case *ReturnStmt:
 		fmt.Printf("%sReturn: ", indent)
 		printExpression(stmt.Value, "")
@@ -56,6 +74,57 @@ func printStatement(s Statement, indent string) {
 			}
 		}
 		fmt.Println("")
+	case *WhileStmt:
+		fmt.Print(indent, "WhileStmt: ", stmt.Label, "\n", indent+"   ", "Condition: ")
+		printExpression(stmt.Condition, indent+"   ")
+		fmt.Printf(" (Line %d, Column %d)\n", stmt.Line, stmt.Column)
+		fmt.Print(indent+"   ", "Body:\n")
+		for _, stmt := range stmt.Body {
+			printStatement(stmt, indent+"      ")
+		}
+		fmt.Println("")
+	case *ForStmt:
+		direction := "đến"
+		if stmt.Down {
+			direction = "xuống"
+		}
+		fmt.Printf("%sForStmt: %s %s: %s từ ", indent, stmt.Label, stmt.Var.Name, stmt.Var.Type.String())
+		printExpression(stmt.Start, indent+"   ")
+		fmt.Printf(" %s ", direction)
+		printExpression(stmt.End, indent+"   ")
+		if stmt.Step != nil {
+			fmt.Print(" bước ")
+			printExpression(stmt.Step, indent+"   ")
+		}
+		fmt.Printf(" (Line %d, Column %d)\n", stmt.Line, stmt.Column)
+		fmt.Print(indent+"   ", "Body:\n")
+		for _, stmt := range stmt.Body {
+			printStatement(stmt, indent+"      ")
+		}
+		fmt.Println("")
+	case *ForEachStmt:
+		fmt.Printf("%sForEachStmt: %s ", indent, stmt.Label)
+		if stmt.Index != nil {
+			fmt.Printf("%s: %s, ", stmt.Index.Name, stmt.Index.Type.String())
+		}
+		fmt.Printf("%s: %s trong ", stmt.Var.Name, stmt.Var.Type.String())
+		printExpression(stmt.Collection, indent+"   ")
+		fmt.Printf(" (Line %d, Column %d)\n", stmt.Line, stmt.Column)
+		fmt.Print(indent+"   ", "Body:\n")
+		for _, stmt := range stmt.Body {
+			printStatement(stmt, indent+"      ")
+		}
+		fmt.Println("")
+	case *BreakStmt:
+		fmt.Printf("%sBreakStmt: %s (Line %d, Column %d)\n", indent, stmt.Label, stmt.Line, stmt.Column)
+	case *ContinueStmt:
+		fmt.Printf("%sContinueStmt: %s (Line %d, Column %d)\n", indent, stmt.Label, stmt.Line, stmt.Column)
+	case *AssignStmt:
+		fmt.Printf("%sAssignStmt: ", indent)
+		printExpression(stmt.Target, "")
+		fmt.Print(" := ")
+		printExpression(stmt.Value, "")
+		fmt.Printf(" (Line %d, Column %d)\n", stmt.Line, stmt.Column)
 	case *RegExpr:
 		fmt.Printf("%sRegExpr: ", indent)
 		printExpression(stmt.Expr, "")
@@ -71,6 +140,12 @@ func printExpression(e Expression, indent string) {
 		fmt.Printf("Identifier(%s: %s)", expr.Name, expr.Type.String())
 	case *NumberLiteral:
 		fmt.Printf("NumberLiteral(%s: %s)", expr.Value, expr.Type.String())
+	case *StringLiteral:
+		fmt.Printf("StringLiteral(%q: %s)", expr.Value, expr.Type.String())
+	case *CharLiteral:
+		fmt.Printf("CharLiteral(%q: %s)", expr.Value, expr.Type.String())
+	case *BooleanLiteral:
+		fmt.Printf("BooleanLiteral(%t: %s)", expr.Value, expr.Type.String())
 	case *BinaryExpr:
 		fmt.Print("BinaryExpr(\n")
 		fmt.Printf("%s         ", indent)
@@ -80,6 +155,10 @@ func printExpression(e Expression, indent string) {
 		fmt.Printf("%s         ", indent)
 		printExpression(expr.Right, indent+"   ")
 		fmt.Printf("\n%s         )", indent)
+	case *UnaryExpr:
+		fmt.Printf("UnaryExpr(%s ", expr.Operator)
+		printExpression(expr.Operand, indent)
+		fmt.Print(")")
 	case *CallExpr:
 		fmt.Printf("CallExpr: %s(", expr.Name)
 		for i, argument := range expr.Arguments {
@@ -99,6 +178,22 @@ func printExpression(e Expression, indent string) {
 			}
 		}
 		fmt.Printf("\n%s      }", indent)
+	case *StructLiteral:
+		fmt.Printf("StructLiteral: %s{", expr.StructName)
+		i := 0
+		for name, field := range expr.Fields {
+			fmt.Printf("%s: ", name)
+			printExpression(field, indent)
+			if i+1 < len(expr.Fields) {
+				fmt.Print(", ")
+			}
+			i++
+		}
+		fmt.Print("}")
+	case *FieldExpr:
+		fmt.Printf("FieldExpr: ")
+		printExpression(expr.Object, indent)
+		fmt.Printf(".%s", expr.Field)
 	case *ExplicitCast:
 		fmt.Printf("ExplicitCast: %s(", expr.Type.String())
 		printExpression(expr.Argument, indent)
@@ -146,6 +241,9 @@ func compile(args []string, cth CongThuc) *ir.Module {
 			break
 		}
 	}
+	if err := lexer.Err(); err != nil {
+		log.Fatal("Không thể đọc chương trình:\n", err)
+	}
 
 	// Parse tokens
 	parser := NewParser(tokens)